@@ -2,7 +2,9 @@ package main
 
 import (
 	"equation-solver/pkg/sketch"
+	"equation-solver/pkg/solver"
 	"fmt"
+	"runtime"
 	"strconv"
 )
 
@@ -30,9 +32,41 @@ func main() {
 			s.SetDistance(A, B, d.Value)
 		}
 
-		s.SatisfyConstraints()
+		options := solver.DefaultSolverOptions()
+		options.Workers = runtime.NumCPU()
+
+		report := s.SatisfyConstraints(options)
 		s.PrintParams()
 
+		for _, d := range distances {
+			d.Flagged = false
+		}
+
+		for _, issue := range s.Diagnose(options) {
+			distances[issue.Constraint.Index].Flagged = true
+
+			if !issue.Conflicting {
+				fmt.Printf("%v is redundant\n", issue.Constraint)
+				continue
+			}
+
+			fmt.Printf("%v conflicts with %v\n", issue.Constraint, issue.DependsOn)
+			for _, c := range issue.DependsOn {
+				distances[c.Index].Flagged = true
+			}
+		}
+
+		if report.Result != solver.CONVERGED {
+			fmt.Printf("Solver failed: %s after %d iterations, residual %g\n",
+				report.Result, report.Iterations, report.ResidualNorm)
+			if report.Err != nil {
+				fmt.Println(report.Err)
+			}
+			return
+		}
+
+		fmt.Printf("Degrees of freedom: %d\n", s.DegreesOfFreedom().Free)
+
 		newPoints := make([]*Point, 0)
 		newPoints = append(newPoints, points...)
 
@@ -12,8 +12,9 @@ import (
 )
 
 type Distance struct {
-	P1, P2 *Point
-	Value  float64
+	P1, P2  *Point
+	Value   float64
+	Flagged bool // redundant or conflicting
 }
 
 var distances []*Distance
@@ -269,7 +270,11 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		sy1 := y0 - d.P1.Y
 		sx2 := d.P2.X + x0
 		sy2 := y0 - d.P2.Y
-		vector.StrokeLine(screen, float32(sx1), float32(sy1), float32(sx2), float32(sy2), 2, color.RGBA{0, 255, 0, 255}, false)
+		col := color.RGBA{0, 255, 0, 255} // green
+		if d.Flagged {
+			col = color.RGBA{255, 128, 0, 255} // orange
+		}
+		vector.StrokeLine(screen, float32(sx1), float32(sy1), float32(sx2), float32(sy2), 2, col, false)
 		mx := (sx1 + sx2) / 2
 		my := (sy1 + sy2) / 2
 		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%.2f", d.Value), mx, my)
@@ -0,0 +1,107 @@
+package math
+
+import "math"
+
+// Cholesky is the factorization A = LLᵀ of a symmetric positive definite
+// matrix, such as the normal equations JᵀJ. It takes half the work of LU and
+// needs no pivoting.
+type Cholesky struct {
+	l Matrix
+}
+
+// Cholesky factorizes a symmetric matrix, only the lower triangle is read. It
+// returns a *NotPositiveDefiniteError if the matrix isn't positive definite.
+func (m Matrix) Cholesky() (*Cholesky, error) {
+	if !m.IsSquare() {
+		panic(&DimensionError{"factorize", m.Shape(), Shape{m.Cols(), m.Rows()}})
+	}
+
+	n := m.Rows()
+	L := NewMatrix(n, n)
+
+	for j := 0; j < n; j++ {
+		sum := m[j][j]
+		for k := 0; k < j; k++ {
+			sum -= L[j][k] * L[j][k]
+		}
+
+		if sum <= 0 {
+			return nil, &NotPositiveDefiniteError{Column: j, Pivot: sum}
+		}
+		L[j][j] = math.Sqrt(sum)
+
+		for i := j + 1; i < n; i++ {
+			sum := m[i][j]
+			for k := 0; k < j; k++ {
+				sum -= L[i][k] * L[j][k]
+			}
+			L[i][j] = sum / L[j][j]
+		}
+	}
+
+	return &Cholesky{l: L}, nil
+}
+
+// Solve returns x with Ax = b. The factorization succeeded, so the only error
+// is a *DimensionError if b doesn't fit.
+func (c *Cholesky) Solve(b Vector) (Vector, error) {
+	n := len(c.l)
+	if len(b) != n {
+		return nil, &DimensionError{"solve", Shape{n, n}, b.shape()}
+	}
+
+	// Ly = b
+	y := make(Vector, n)
+	for i := 0; i < n; i++ {
+		sum := b[i]
+		for k := 0; k < i; k++ {
+			sum -= c.l[i][k] * y[k]
+		}
+		y[i] = sum / c.l[i][i]
+	}
+
+	// Lᵀx = y
+	x := make(Vector, n)
+	for i := n - 1; i >= 0; i-- {
+		sum := y[i]
+		for k := i + 1; k < n; k++ {
+			sum -= c.l[k][i] * x[k]
+		}
+		x[i] = sum / c.l[i][i]
+	}
+
+	return x, nil
+}
+
+func (c *Cholesky) Determinant() float64 {
+	result := 1.0
+	for i := range c.l {
+		result *= c.l[i][i] * c.l[i][i]
+	}
+
+	return result
+}
+
+// Rank counts the diagonal entries of L that aren't zero within the
+// tolerance, relative to the largest one. Every one of them is positive, but
+// a nearly singular matrix can still have tiny ones.
+func (c *Cholesky) Rank(tolerance float64) int {
+	largest := 0.0
+	for i := range c.l {
+		largest = math.Max(largest, c.l[i][i])
+	}
+
+	result := 0
+	for i := range c.l {
+		if c.l[i][i] > tolerance*largest {
+			result++
+		}
+	}
+
+	return result
+}
+
+// L returns a copy of the lower triangular factor
+func (c *Cholesky) L() Matrix {
+	return c.l.Copy()
+}
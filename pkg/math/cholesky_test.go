@@ -0,0 +1,64 @@
+package math
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCholesky_L(t *testing.T) {
+	m := Matrix{
+		{4, 12, -16},
+		{12, 37, -43},
+		{-16, -43, 98},
+	}
+
+	c, err := m.Cholesky()
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{2, 0, 0}, c.L()[0], 1e-12)
+	assert.InDeltaSlice(t, Vector{6, 1, 0}, c.L()[1], 1e-12)
+	assert.InDeltaSlice(t, Vector{-8, 5, 3}, c.L()[2], 1e-12)
+}
+
+func TestCholesky_Solve(t *testing.T) {
+	m := Matrix{
+		{4, 12, -16},
+		{12, 37, -43},
+		{-16, -43, 98},
+	}
+
+	c, _ := m.Cholesky()
+	x, err := c.Solve(Vector{-8, -18, 71})
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{-1, 1, 1}, x, 1e-12)
+}
+
+func TestCholesky_NotPositiveDefinite(t *testing.T) {
+	_, err := Matrix{{1, 2}, {2, 1}}.Cholesky()
+	assert.Equal(t, &NotPositiveDefiniteError{Column: 1, Pivot: -3}, err)
+
+	_, err = Matrix{{0, 0}, {0, 1}}.Cholesky()
+	assert.Equal(t, &NotPositiveDefiniteError{Column: 0, Pivot: 0}, err)
+}
+
+func TestCholesky_Determinant(t *testing.T) {
+	c, _ := Matrix{{4, 12, -16}, {12, 37, -43}, {-16, -43, 98}}.Cholesky()
+
+	assert.InDelta(t, 36, c.Determinant(), 1e-9)
+}
+
+func TestCholesky_Rank(t *testing.T) {
+	// the second pivot is sqrt(1e-12) = 1e-6
+	c, _ := Matrix{{1, 1}, {1, 1 + 1e-12}}.Cholesky()
+	assert.Equal(t, 2, c.Rank(1e-9))
+	assert.Equal(t, 1, c.Rank(1e-4))
+
+	c, _ = Matrix{{2, 1}, {1, 2}}.Cholesky()
+	assert.Equal(t, 2, c.Rank(1e-9))
+}
+
+func TestCholesky_NotSquare(t *testing.T) {
+	assert.PanicsWithError(t, "can't factorize, dimensions don't match: 1x2 and 2x1", func() { Matrix{{1, 2}}.Cholesky() })
+}
@@ -0,0 +1,46 @@
+package math
+
+import "fmt"
+
+// SingularMatrixError is returned when elimination finds no usable pivot.
+// Column is the first column that depends linearly on the ones before it.
+type SingularMatrixError struct {
+	Column int
+	Pivot  float64
+}
+
+func (e *SingularMatrixError) Error() string {
+	return fmt.Sprintf("matrix is singular, column %d has pivot %g", e.Column, e.Pivot)
+}
+
+// NotPositiveDefiniteError is returned by a Cholesky factorization when a
+// diagonal entry would be the square root of Pivot <= 0
+type NotPositiveDefiniteError struct {
+	Column int
+	Pivot  float64
+}
+
+func (e *NotPositiveDefiniteError) Error() string {
+	return fmt.Sprintf("matrix isn't positive definite, column %d has pivot %g", e.Column, e.Pivot)
+}
+
+// Shape is the size of a matrix, a vector is a single column
+type Shape struct {
+	Rows int
+	Cols int
+}
+
+func (s Shape) String() string {
+	return fmt.Sprintf("%dx%d", s.Rows, s.Cols)
+}
+
+// DimensionError is returned when the operands of Operation don't fit together
+type DimensionError struct {
+	Operation string
+	Left      Shape
+	Right     Shape
+}
+
+func (e *DimensionError) Error() string {
+	return fmt.Sprintf("can't %s, dimensions don't match: %v and %v", e.Operation, e.Left, e.Right)
+}
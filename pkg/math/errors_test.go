@@ -0,0 +1,25 @@
+package math
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors_SingularMatrix(t *testing.T) {
+	err := &SingularMatrixError{Column: 1, Pivot: 0}
+
+	assert.Equal(t, "matrix is singular, column 1 has pivot 0", err.Error())
+}
+
+func TestErrors_NotPositiveDefinite(t *testing.T) {
+	err := &NotPositiveDefiniteError{Column: 2, Pivot: -1}
+
+	assert.Equal(t, "matrix isn't positive definite, column 2 has pivot -1", err.Error())
+}
+
+func TestErrors_Dimension(t *testing.T) {
+	err := &DimensionError{Operation: "multiply", Left: Shape{2, 3}, Right: Shape{2, 1}}
+
+	assert.Equal(t, "can't multiply, dimensions don't match: 2x3 and 2x1", err.Error())
+}
@@ -0,0 +1,42 @@
+package math
+
+import "math"
+
+// Factorization is a decomposition of a matrix that linear systems can be
+// solved with repeatedly
+type Factorization interface {
+	// Solve returns x with Ax = b, in the least squares sense for a QR of a
+	// tall matrix
+	Solve(b Vector) (Vector, error)
+	Determinant() float64
+	// Rank counts the pivots that aren't zero within the tolerance, relative
+	// to the largest one
+	Rank(tolerance float64) int
+}
+
+// pivots smaller than this, relative to the largest entry, count as zero
+const PivotTolerance = 1e-12
+
+func largestAbs(m Matrix) float64 {
+	result := 0.0
+	for _, row := range m {
+		result = math.Max(result, row.NormInf())
+	}
+
+	return result
+}
+
+// backSubstitute solves Ux = y for the upper triangular n x n part of U
+func backSubstitute(U Matrix, y Vector, n int) Vector {
+	x := make(Vector, n)
+
+	for i := n - 1; i >= 0; i-- {
+		sum := 0.0
+		for j := i + 1; j < n; j++ {
+			sum += U[i][j] * x[j]
+		}
+		x[i] = (y[i] - sum) / U[i][i]
+	}
+
+	return x
+}
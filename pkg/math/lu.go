@@ -0,0 +1,115 @@
+package math
+
+import "math"
+
+// LU is the factorization PA = LU of a square matrix with partial pivoting.
+// L has a unit diagonal and is stored below the diagonal of lu, U on and
+// above it.
+type LU struct {
+	lu    Matrix
+	perm  []int   // perm[i] is the row of A that ended up in row i
+	sign  float64 // determinant of P
+	scale float64 // largest entry of A
+}
+
+// LU factorizes a square matrix. It never fails, a singular matrix is
+// reported by Solve.
+func (m Matrix) LU() *LU {
+	if !m.IsSquare() {
+		panic(&DimensionError{"factorize", m.Shape(), Shape{m.Cols(), m.Rows()}})
+	}
+
+	n := m.Rows()
+	A := m.Copy()
+
+	lu := &LU{lu: A, perm: make([]int, n), sign: 1, scale: largestAbs(A)}
+	for i := range lu.perm {
+		lu.perm[i] = i
+	}
+
+	for i := 0; i < n; i++ {
+		pivotRow := i
+
+		for j := i + 1; j < n; j++ {
+			if math.Abs(A[j][i]) > math.Abs(A[pivotRow][i]) {
+				pivotRow = j
+			}
+		}
+
+		if pivotRow != i {
+			A.SwapRows(pivotRow, i)
+			lu.perm[pivotRow], lu.perm[i] = lu.perm[i], lu.perm[pivotRow]
+			lu.sign = -lu.sign
+		}
+
+		if math.Abs(A[i][i]) <= PivotTolerance*lu.scale {
+			// nothing to eliminate with, the column depends on the ones before
+			continue
+		}
+
+		for j := i + 1; j < n; j++ {
+			factor := A[j][i] / A[i][i]
+			for k := i + 1; k < n; k++ {
+				A[j][k] -= A[i][k] * factor
+			}
+			A[j][i] = factor
+		}
+	}
+
+	return lu
+}
+
+// Solve returns x with Ax = b, or a *SingularMatrixError naming the first
+// column without a usable pivot. A b of the wrong length is a *DimensionError.
+func (lu *LU) Solve(b Vector) (Vector, error) {
+	n := len(lu.lu)
+	if len(b) != n {
+		return nil, &DimensionError{"solve", Shape{n, n}, b.shape()}
+	}
+
+	for i := 0; i < n; i++ {
+		if math.Abs(lu.lu[i][i]) <= PivotTolerance*lu.scale {
+			return nil, &SingularMatrixError{Column: i, Pivot: lu.lu[i][i]}
+		}
+	}
+
+	y := make(Vector, n)
+	for i := range y {
+		y[i] = b[lu.perm[i]]
+	}
+
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			y[j] -= y[i] * lu.lu[j][i]
+		}
+	}
+
+	return backSubstitute(lu.lu, y, n), nil
+}
+
+func (lu *LU) Determinant() float64 {
+	result := lu.sign
+	for i := range lu.lu {
+		result *= lu.lu[i][i]
+	}
+
+	return result
+}
+
+// Rank counts the nonzero pivots of U. Partial pivoting isn't rank revealing,
+// for nearly dependent columns QR gives a more reliable answer.
+func (lu *LU) Rank(tolerance float64) int {
+	largest := 0.0
+	for i := range lu.lu {
+		largest = math.Max(largest, math.Abs(lu.lu[i][i]))
+	}
+
+	result := 0
+	for i := range lu.lu {
+		if math.Abs(lu.lu[i][i]) > tolerance*largest {
+			result++
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,115 @@
+package math
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLU_Solve(t *testing.T) {
+	m := Matrix{
+		{2, 1, -1},
+		{-3, -1, 2},
+		{-2, 1, 2},
+	}
+
+	x, err := m.LU().Solve(Vector{8, -11, -3})
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{2, 3, -1}, x, 1e-12)
+}
+
+func TestLU_SolveNeedsPivoting(t *testing.T) {
+	// without row swaps the first pivot is zero
+	m := Matrix{
+		{0, 1},
+		{1, 1},
+	}
+
+	x, err := m.LU().Solve(Vector{2, 3})
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{1, 2}, x, 1e-12)
+}
+
+func TestLU_SolveRepeatedly(t *testing.T) {
+	m := Matrix{
+		{4, 3},
+		{6, 3},
+	}
+	lu := m.LU()
+
+	for _, b := range []Vector{{1, 0}, {0, 1}, {7, 9}} {
+		x, err := lu.Solve(b)
+
+		assert.NoError(t, err)
+		Ax := m.MultiplyRight(NewMatrixFromColVec(x))
+		assert.InDelta(t, b[0], Ax[0][0], 1e-12)
+		assert.InDelta(t, b[1], Ax[1][0], 1e-12)
+	}
+}
+
+func TestLU_Singular(t *testing.T) {
+	m := Matrix{
+		{1, 2},
+		{2, 4},
+	}
+
+	_, err := m.LU().Solve(Vector{1, 2})
+
+	assert.Equal(t, &SingularMatrixError{Column: 1, Pivot: 0}, err)
+}
+
+func TestLU_ZeroColumn(t *testing.T) {
+	m := Matrix{
+		{0, 1},
+		{0, 2},
+	}
+
+	lu := m.LU()
+	_, err := lu.Solve(Vector{1, 2})
+
+	assert.Equal(t, &SingularMatrixError{Column: 0, Pivot: 0}, err)
+	assert.Equal(t, 1, lu.Rank(PivotTolerance))
+}
+
+func TestLU_DoesntModify(t *testing.T) {
+	m := Matrix{
+		{1, 2},
+		{3, 4},
+	}
+
+	m.LU()
+
+	assert.Equal(t, Matrix{{1, 2}, {3, 4}}, m)
+}
+
+func TestLU_Determinant(t *testing.T) {
+	assert.InDelta(t, -2, Matrix{{1, 2}, {3, 4}}.LU().Determinant(), 1e-12)
+	assert.InDelta(t, -306, Matrix{{6, 1, 1}, {4, -2, 5}, {2, 8, 7}}.LU().Determinant(), 1e-12)
+	assert.InDelta(t, 0, Matrix{{1, 2}, {2, 4}}.LU().Determinant(), 1e-12)
+	// one swap flips the sign
+	assert.InDelta(t, -1, Matrix{{0, 1}, {1, 0}}.LU().Determinant(), 1e-12)
+}
+
+func TestLU_Rank(t *testing.T) {
+	assert.Equal(t, 3, Matrix{{2, 1, -1}, {-3, -1, 2}, {-2, 1, 2}}.LU().Rank(PivotTolerance))
+	assert.Equal(t, 2, Matrix{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}.LU().Rank(1e-9))
+	assert.Equal(t, 0, Matrix{{0, 0}, {0, 0}}.LU().Rank(PivotTolerance))
+}
+
+func TestLU_Factorization(t *testing.T) {
+	var f Factorization = Matrix{{1}}.LU()
+
+	assert.Equal(t, 1.0, f.Determinant())
+}
+
+func TestLU_SolveDimensions(t *testing.T) {
+	_, err := Matrix{{1, 0}, {0, 1}}.LU().Solve(Vector{1, 2, 3})
+
+	assert.Equal(t, &DimensionError{"solve", Shape{2, 2}, Shape{3, 1}}, err)
+}
+
+func TestLU_NotSquare(t *testing.T) {
+	assert.PanicsWithError(t, "can't factorize, dimensions don't match: 1x2 and 2x1", func() { Matrix{{1, 2}}.LU() })
+}
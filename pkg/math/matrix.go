@@ -24,19 +24,59 @@ func NewMatrix(rows int, cols int) Matrix {
 	return m
 }
 
-func (m Matrix) AugmentVec(vec Vector) {
-	if len(m) != len(vec) {
-		panic("dimensions don't match, can't augment matrix")
+// AugmentVec returns a new matrix with vec appended as the last column
+func (m Matrix) AugmentVec(vec Vector) Matrix {
+	result, err := m.TryAugmentVec(vec)
+	if err != nil {
+		panic(err)
 	}
 
-	for i := 0; i < len(m); i++ {
-		m[i] = append(m[i], vec[i])
+	return result
+}
+
+// TryAugmentVec is AugmentVec that returns a *DimensionError instead of
+// panicking if the vector doesn't have a value for every row
+func (m Matrix) TryAugmentVec(vec Vector) (Matrix, error) {
+	return m.TryAugment(NewMatrixFromColVec(vec))
+}
+
+// AugmentVecInPlace appends vec to the rows of m. The rows may be reallocated,
+// other matrices sharing them don't see the new column.
+func (m Matrix) AugmentVecInPlace(vec Vector) {
+	m.AugmentInPlace(NewMatrixFromColVec(vec))
+}
+
+// Augment returns a new matrix with the columns of m2 appended
+func (m Matrix) Augment(m2 Matrix) Matrix {
+	result, err := m.TryAugment(m2)
+	if err != nil {
+		panic(err)
 	}
+
+	return result
 }
 
-func (m Matrix) Augment(m2 Matrix) {
+// TryAugment is Augment that returns a *DimensionError instead of panicking
+// if the matrices don't have the same number of rows
+func (m Matrix) TryAugment(m2 Matrix) (Matrix, error) {
 	if len(m) != len(m2) {
-		panic("dimensions don't match, can't augment matrix")
+		return nil, &DimensionError{"augment matrix", m.Shape(), m2.Shape()}
+	}
+
+	result := make(Matrix, len(m))
+	for i := range m {
+		result[i] = make(Vector, 0, len(m[i])+len(m2[i]))
+		result[i] = append(append(result[i], m[i]...), m2[i]...)
+	}
+
+	return result, nil
+}
+
+// AugmentInPlace appends the columns of m2 to the rows of m, see
+// AugmentVecInPlace
+func (m Matrix) AugmentInPlace(m2 Matrix) {
+	if len(m) != len(m2) {
+		panic(&DimensionError{"augment matrix", m.Shape(), m2.Shape()})
 	}
 
 	for i := 0; i < len(m); i++ {
@@ -48,12 +88,21 @@ func (m Matrix) Rows() int {
 	return len(m)
 }
 
+// Cols returns the length of the first row, 0 for an empty matrix
 func (m Matrix) Cols() int {
+	if len(m) == 0 {
+		return 0
+	}
+
 	return len(m[0])
 }
 
 func (m Matrix) Size() (int, int) {
-	return len(m), len(m[0])
+	return len(m), m.Cols()
+}
+
+func (m Matrix) Shape() Shape {
+	return Shape{m.Rows(), m.Cols()}
 }
 
 func (m Matrix) IsSquare() bool {
@@ -62,6 +111,7 @@ func (m Matrix) IsSquare() bool {
 	return rows == cols
 }
 
+// SwapRows exchanges two rows of m in place
 func (m Matrix) SwapRows(row1 int, row2 int) {
 	temp := m[row1]
 	m[row1] = m[row2]
@@ -88,40 +138,49 @@ func (m Matrix) String() string {
 	return result
 }
 
-func (m *Matrix) Copy() *Matrix {
-	result := Matrix{}
-	rows, cols := m.Size()
-
-	for i := 0; i < rows; i++ {
-		result = append(result, Vector{})
-
-		for j := 0; j < cols; j++ {
-			result[i] = append(result[i], (*m)[i][j])
-		}
+// Copy returns a deep copy, no rows are shared
+func (m Matrix) Copy() Matrix {
+	result := make(Matrix, len(m))
+	for i := range m {
+		result[i] = append(Vector{}, m[i]...)
 	}
 
-	return &result
+	return result
 }
 
-func (m *Matrix) Transpose() *Matrix {
-	result := NewMatrix(m.Cols(), m.Rows())
-
+// Transpose returns a new matrix, m is left as it is
+func (m Matrix) Transpose() Matrix {
 	rows, cols := m.Size()
+	result := NewMatrix(cols, rows)
 
 	for i := 0; i < rows; i++ {
 		for j := 0; j < cols; j++ {
-			result[j][i] = (*m)[i][j]
+			result[j][i] = m[i][j]
 		}
 	}
 
-	*m = result
+	return result
+}
 
-	return &result
+// TransposeInPlace replaces m with its transpose
+func (m *Matrix) TransposeInPlace() {
+	*m = m.Transpose()
 }
 
 func (left Matrix) MultiplyRight(right Matrix) Matrix {
+	result, err := left.TryMultiplyRight(right)
+	if err != nil {
+		panic(err)
+	}
+
+	return result
+}
+
+// TryMultiplyRight is MultiplyRight that returns a *DimensionError instead of
+// panicking if the columns of left don't match the rows of right
+func (left Matrix) TryMultiplyRight(right Matrix) (Matrix, error) {
 	if left.Cols() != right.Rows() {
-		panic("can't multiply, dimensions don't match")
+		return nil, &DimensionError{"multiply", left.Shape(), right.Shape()}
 	}
 
 	result := NewMatrix(left.Rows(), right.Cols())
@@ -138,5 +197,5 @@ func (left Matrix) MultiplyRight(right Matrix) Matrix {
 		}
 	}
 
-	return result
+	return result, nil
 }
@@ -43,9 +43,21 @@ func TestMatrix_AugmentVec(t *testing.T) {
 		{3, 4, 6},
 	}
 
-	m.AugmentVec(vec)
+	got := m.AugmentVec(vec)
 
-	assert.Equal(t, expected, m)
+	assert.Equal(t, expected, got)
+	assert.Equal(t, Matrix{{1, 2}, {3, 4}}, m)
+}
+
+func TestMatrix_AugmentVecInPlace(t *testing.T) {
+	m := Matrix{
+		{1, 2},
+		{3, 4},
+	}
+
+	m.AugmentVecInPlace(Vector{5, 6})
+
+	assert.Equal(t, Matrix{{1, 2, 5}, {3, 4, 6}}, m)
 }
 
 func TestMatrix_Augment(t *testing.T) {
@@ -63,10 +75,34 @@ func TestMatrix_Augment(t *testing.T) {
 		{3, 4, 7, 8},
 	}
 
-	m.Augment(m2)
+	got := m.Augment(m2)
 
-	assert.Equal(t, expected, m)
+	assert.Equal(t, expected, got)
+	assert.Equal(t, Matrix{{1, 2}, {3, 4}}, m)
+}
+
+func TestMatrix_AugmentInPlace(t *testing.T) {
+	m := Matrix{
+		{1, 2},
+		{3, 4},
+	}
+
+	m.AugmentInPlace(Matrix{{5}, {6}})
+
+	assert.Equal(t, Matrix{{1, 2, 5}, {3, 4, 6}}, m)
+	assert.Panics(t, func() { m.AugmentInPlace(Matrix{{5}}) })
+}
+
+func TestMatrix_AugmentDoesntAlias(t *testing.T) {
+	// rows with spare capacity must not be shared with the result
+	row := make(Vector, 2, 10)
+	m := Matrix{row}
 
+	a := m.AugmentVec(Vector{1})
+	b := m.AugmentVec(Vector{2})
+
+	assert.Equal(t, Matrix{{0, 0, 1}}, a)
+	assert.Equal(t, Matrix{{0, 0, 2}}, b)
 }
 
 func TestSwapMatrixRows(t *testing.T) {
@@ -102,9 +138,9 @@ func TestMatrix_Copy(t *testing.T) {
 		{3, 4},
 	}
 	m2 := m.Copy()
-	(*m2)[0][0] = 0
-	(*m2)[1][1] = 0
-	assert.Equal(t, Matrix{{0, 2}, {3, 0}}, *m2)
+	m2[0][0] = 0
+	m2[1][1] = 0
+	assert.Equal(t, Matrix{{0, 2}, {3, 0}}, m2)
 	assert.Equal(t, Matrix{{1, 2}, {3, 4}}, m)
 }
 
@@ -114,16 +150,29 @@ func TestMatrix_Transpose(t *testing.T) {
 		{3, 4},
 		{5, 6},
 	}
-	m.Transpose()
+	got := m.Transpose()
 
-	fmt.Println(m)
+	fmt.Println(got)
 
 	expected := Matrix{
 		{1, 3, 5},
 		{2, 4, 6},
 	}
 
-	assert.Equal(t, expected, m)
+	assert.Equal(t, expected, got)
+	assert.Equal(t, Matrix{{1, 2}, {3, 4}, {5, 6}}, m)
+}
+
+func TestMatrix_TransposeInPlace(t *testing.T) {
+	m := Matrix{
+		{1, 2},
+		{3, 4},
+		{5, 6},
+	}
+
+	m.TransposeInPlace()
+
+	assert.Equal(t, Matrix{{1, 3, 5}, {2, 4, 6}}, m)
 }
 
 func TestMatrix_Multiply(t *testing.T) {
@@ -146,3 +195,52 @@ func TestMatrix_Multiply(t *testing.T) {
 
 	assert.Equal(t, expected, got)
 }
+
+func TestMatrix_TryAugmentVec(t *testing.T) {
+	m := Matrix{
+		{1, 2},
+		{3, 4},
+	}
+
+	got, err := m.TryAugmentVec(Vector{5})
+
+	assert.Equal(t, &DimensionError{"augment matrix", Shape{2, 2}, Shape{1, 1}}, err)
+	assert.Nil(t, got)
+	assert.Equal(t, Matrix{{1, 2}, {3, 4}}, m)
+}
+
+func TestMatrix_TryAugment(t *testing.T) {
+	m := Matrix{
+		{1, 2},
+		{3, 4},
+	}
+
+	_, err := m.TryAugment(Matrix{{5, 6, 7}})
+
+	assert.Equal(t, &DimensionError{"augment matrix", Shape{2, 2}, Shape{1, 3}}, err)
+	assert.Panics(t, func() { m.Augment(Matrix{{5, 6, 7}}) })
+}
+
+func TestMatrix_TryMultiplyRight(t *testing.T) {
+	left := Matrix{
+		{1, 2, 3},
+	}
+
+	got, err := left.TryMultiplyRight(Matrix{{1}, {2}, {3}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, Matrix{{14}}, got)
+
+	got, err = left.TryMultiplyRight(Matrix{{1, 2}})
+
+	assert.Equal(t, &DimensionError{"multiply", Shape{1, 3}, Shape{1, 2}}, err)
+	assert.Nil(t, got)
+}
+
+func TestMatrix_EmptySize(t *testing.T) {
+	rows, cols := Matrix{}.Size()
+
+	assert.Equal(t, 0, rows)
+	assert.Equal(t, 0, cols)
+	assert.Equal(t, Shape{0, 0}, Matrix{}.Shape())
+}
@@ -0,0 +1,228 @@
+package math
+
+import "math"
+
+// QR is the factorization AP = QR of an m x n matrix with Householder
+// reflections and column pivoting, which moves the dependent columns to the
+// end so that the diagonal of R reveals the rank. Q is kept as the list of
+// reflections.
+type QR struct {
+	rows       int
+	cols       int
+	r          Matrix   // min(m, n) x n, upper triangular
+	reflectors []Vector // step k reflects rows k.. about reflectors[k], nil if it was skipped
+	perm       []int    // perm[k] is the column of A that ended up in column k
+	sign       float64  // determinant of Q and P together
+}
+
+// QR factorizes a matrix of any shape. It never fails, rank deficiency is
+// reported by Solve and Rank.
+func (m Matrix) QR() *QR {
+	rows, cols := len(m), 0
+	if rows > 0 {
+		cols = len(m[0])
+	}
+
+	A := m.Copy()
+	steps := min(rows, cols)
+
+	qr := &QR{
+		rows:       rows,
+		cols:       cols,
+		reflectors: make([]Vector, steps),
+		perm:       make([]int, cols),
+		sign:       1,
+	}
+	for j := range qr.perm {
+		qr.perm[j] = j
+	}
+
+	for k := 0; k < steps; k++ {
+		// the column with the largest remaining norm goes next
+		pivotCol, largest := k, -1.0
+		for j := k; j < cols; j++ {
+			sum := 0.0
+			for i := k; i < rows; i++ {
+				sum += A[i][j] * A[i][j]
+			}
+			if sum > largest {
+				pivotCol, largest = j, sum
+			}
+		}
+
+		if pivotCol != k {
+			for i := range A {
+				A[i][k], A[i][pivotCol] = A[i][pivotCol], A[i][k]
+			}
+			qr.perm[k], qr.perm[pivotCol] = qr.perm[pivotCol], qr.perm[k]
+			qr.sign = -qr.sign
+		}
+
+		length := math.Sqrt(largest)
+		if length == 0 {
+			continue
+		}
+
+		// reflect the column onto -sign(x0)|x| e0, the sign avoids cancellation
+		alpha := -math.Copysign(length, A[k][k])
+		v := make(Vector, rows-k)
+		for i := k; i < rows; i++ {
+			v[i-k] = A[i][k]
+		}
+		v[0] -= alpha
+
+		vv := 0.0
+		for _, x := range v {
+			vv += x * x
+		}
+		if vv == 0 {
+			continue
+		}
+
+		for j := k + 1; j < cols; j++ {
+			s := 0.0
+			for i := k; i < rows; i++ {
+				s += v[i-k] * A[i][j]
+			}
+			s *= 2 / vv
+			for i := k; i < rows; i++ {
+				A[i][j] -= s * v[i-k]
+			}
+		}
+
+		A[k][k] = alpha
+		for i := k + 1; i < rows; i++ {
+			A[i][k] = 0
+		}
+
+		qr.reflectors[k] = v
+		qr.sign = -qr.sign
+	}
+
+	qr.r = A[:steps]
+	for i := range qr.r {
+		for j := 0; j < i; j++ {
+			qr.r[i][j] = 0
+		}
+	}
+
+	return qr
+}
+
+// applyQt returns Qᵀb
+func (qr *QR) applyQt(b Vector) Vector {
+	y := append(Vector{}, b...)
+
+	for k, v := range qr.reflectors {
+		if v == nil {
+			continue
+		}
+
+		vv, s := 0.0, 0.0
+		for i, x := range v {
+			vv += x * x
+			s += x * y[k+i]
+		}
+		s *= 2 / vv
+		for i, x := range v {
+			y[k+i] -= s * x
+		}
+	}
+
+	return y
+}
+
+// Solve returns the x that minimizes |Ax - b|, the exact solution if A is
+// square. It needs at least as many rows as columns and full column rank,
+// otherwise it returns a *SingularMatrixError naming the first dependent
+// column. A b of the wrong length is a *DimensionError.
+func (qr *QR) Solve(b Vector) (Vector, error) {
+	if len(b) != qr.rows {
+		return nil, &DimensionError{"solve", Shape{qr.rows, qr.cols}, b.shape()}
+	}
+
+	if qr.rows < qr.cols {
+		return nil, &SingularMatrixError{Column: qr.perm[qr.rows], Pivot: 0}
+	}
+
+	largest := qr.largestPivot()
+	for k := 0; k < qr.cols; k++ {
+		if math.Abs(qr.r[k][k]) <= PivotTolerance*largest || largest == 0 {
+			return nil, &SingularMatrixError{Column: qr.perm[k], Pivot: qr.r[k][k]}
+		}
+	}
+
+	z := backSubstitute(qr.r, qr.applyQt(b), qr.cols)
+
+	x := make(Vector, qr.cols)
+	for k, j := range qr.perm {
+		x[j] = z[k]
+	}
+
+	return x, nil
+}
+
+// ResidualNorm returns min |Ax - b|, the part of b that Q spans beyond the
+// columns of A. It is zero for a consistent system.
+func (qr *QR) ResidualNorm(b Vector) float64 {
+	if len(b) != qr.rows {
+		panic(&DimensionError{"solve", Shape{qr.rows, qr.cols}, b.shape()})
+	}
+
+	y := qr.applyQt(b)
+
+	sum := 0.0
+	for i := min(qr.rows, qr.cols); i < qr.rows; i++ {
+		sum += y[i] * y[i]
+	}
+
+	return math.Sqrt(sum)
+}
+
+func (qr *QR) Determinant() float64 {
+	if qr.rows != qr.cols {
+		panic(&DimensionError{"take determinant", Shape{qr.rows, qr.cols}, Shape{qr.cols, qr.rows}})
+	}
+
+	result := qr.sign
+	for k := range qr.r {
+		result *= qr.r[k][k]
+	}
+
+	return result
+}
+
+// Rank counts the diagonal entries of R that aren't zero within the tolerance,
+// relative to the largest one
+func (qr *QR) Rank(tolerance float64) int {
+	largest := qr.largestPivot()
+
+	result := 0
+	for k := range qr.r {
+		if math.Abs(qr.r[k][k]) > tolerance*largest {
+			result++
+		}
+	}
+
+	return result
+}
+
+// R returns a copy of the upper triangular factor
+func (qr *QR) R() Matrix {
+	return qr.r.Copy()
+}
+
+// Permutation returns the column order of R, entry k is the column of A that
+// ended up in column k
+func (qr *QR) Permutation() []int {
+	return append([]int{}, qr.perm...)
+}
+
+func (qr *QR) largestPivot() float64 {
+	result := 0.0
+	for k := range qr.r {
+		result = math.Max(result, math.Abs(qr.r[k][k]))
+	}
+
+	return result
+}
@@ -0,0 +1,130 @@
+package math
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQR_Solve(t *testing.T) {
+	m := Matrix{
+		{2, 1, -1},
+		{-3, -1, 2},
+		{-2, 1, 2},
+	}
+
+	x, err := m.QR().Solve(Vector{8, -11, -3})
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{2, 3, -1}, x, 1e-12)
+}
+
+func TestQR_LeastSquares(t *testing.T) {
+	// the line through (0, 1), (1, 3), (2, 4) closest in the least squares sense
+	m := Matrix{
+		{1, 0},
+		{1, 1},
+		{1, 2},
+	}
+	b := Vector{1, 3, 4}
+
+	qr := m.QR()
+	x, err := qr.Solve(b)
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{7.0 / 6, 1.5}, x, 1e-12)
+	// residuals are -1/6, 1/3, -1/6
+	assert.InDelta(t, math.Sqrt(1.0/6), qr.ResidualNorm(b), 1e-12)
+}
+
+func TestQR_Consistent(t *testing.T) {
+	m := Matrix{
+		{1, 0},
+		{0, 1},
+		{1, 1},
+	}
+	b := Vector{1, 2, 3}
+
+	qr := m.QR()
+	x, err := qr.Solve(b)
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{1, 2}, x, 1e-12)
+	assert.InDelta(t, 0, qr.ResidualNorm(b), 1e-12)
+}
+
+func TestQR_Singular(t *testing.T) {
+	m := Matrix{
+		{1, 2},
+		{2, 4},
+		{3, 6},
+	}
+
+	_, err := m.QR().Solve(Vector{1, 2, 3})
+
+	assert.IsType(t, &SingularMatrixError{}, err)
+}
+
+func TestQR_Wide(t *testing.T) {
+	_, err := Matrix{{1, 1}}.QR().Solve(Vector{1})
+
+	assert.IsType(t, &SingularMatrixError{}, err)
+}
+
+func TestQR_R(t *testing.T) {
+	m := Matrix{
+		{12, -51, 4},
+		{6, 167, -68},
+		{-4, 24, -41},
+	}
+
+	qr := m.QR()
+	R := qr.R()
+
+	for i := range R {
+		for j := 0; j < i; j++ {
+			assert.Equal(t, 0.0, R[i][j])
+		}
+	}
+
+	// column pivoting keeps the diagonal decreasing
+	assert.GreaterOrEqual(t, math.Abs(R[0][0]), math.Abs(R[1][1]))
+	assert.GreaterOrEqual(t, math.Abs(R[1][1]), math.Abs(R[2][2]))
+
+	// the largest column comes first, |(-51, 167, 24)| = 176.26...
+	assert.Equal(t, 1, qr.Permutation()[0])
+	assert.InDelta(t, math.Sqrt(51*51+167*167+24*24), math.Abs(R[0][0]), 1e-9)
+}
+
+func TestQR_DoesntModify(t *testing.T) {
+	m := Matrix{
+		{1, 2},
+		{3, 4},
+	}
+
+	m.QR()
+
+	assert.Equal(t, Matrix{{1, 2}, {3, 4}}, m)
+}
+
+func TestQR_Determinant(t *testing.T) {
+	assert.InDelta(t, -2, Matrix{{1, 2}, {3, 4}}.QR().Determinant(), 1e-12)
+	assert.InDelta(t, -306, Matrix{{6, 1, 1}, {4, -2, 5}, {2, 8, 7}}.QR().Determinant(), 1e-9)
+	assert.InDelta(t, -1, Matrix{{0, 1}, {1, 0}}.QR().Determinant(), 1e-12)
+	assert.InDelta(t, 6, Matrix{{2, 0}, {0, 3}}.QR().Determinant(), 1e-12)
+	assert.PanicsWithError(t, "can't take determinant, dimensions don't match: 1x2 and 2x1", func() { Matrix{{1, 2}}.QR().Determinant() })
+}
+
+func TestQR_ResidualNormDimensions(t *testing.T) {
+	assert.PanicsWithError(t, "can't solve, dimensions don't match: 2x1 and 3x1", func() { Matrix{{1}, {2}}.QR().ResidualNorm(Vector{1, 2, 3}) })
+}
+
+func TestQR_Rank(t *testing.T) {
+	assert.Equal(t, 0, Matrix{{0, 0}, {0, 0}}.QR().Rank(1e-9))
+	assert.Equal(t, 2, Matrix{{1, 2}, {3, 4}}.QR().Rank(1e-9))
+	assert.Equal(t, 1, Matrix{{1, 2}, {2, 4}, {3, 6}}.QR().Rank(1e-9))
+	assert.Equal(t, 2, Matrix{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}.QR().Rank(1e-9))
+	assert.Equal(t, 1, Matrix{{1, 1}, {1, 1 + 1e-12}}.QR().Rank(1e-9))
+	assert.Equal(t, 2, Matrix{{1, 0, 1}, {0, 1, 1}}.QR().Rank(1e-9))
+}
@@ -0,0 +1,369 @@
+package math
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// SparseEntry is one nonzero entry of a sparse matrix
+type SparseEntry struct {
+	Row   int
+	Col   int
+	Value float64
+}
+
+// SparseMatrix stores only the nonzero entries, in compressed sparse row
+// form. The entries of row i are values[rowStart[i]:rowStart[i+1]], in the
+// columns colIndex[rowStart[i]:rowStart[i+1]] sorted increasingly.
+type SparseMatrix struct {
+	rows     int
+	cols     int
+	rowStart []int
+	colIndex []int
+	values   []float64
+}
+
+// NewSparseMatrix builds a matrix from its entries in any order. Entries in
+// the same position are summed, zeros are dropped.
+func NewSparseMatrix(rows int, cols int, entries []SparseEntry) SparseMatrix {
+	sorted := append([]SparseEntry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Row != sorted[j].Row {
+			return sorted[i].Row < sorted[j].Row
+		}
+		return sorted[i].Col < sorted[j].Col
+	})
+
+	m := SparseMatrix{rows: rows, cols: cols, rowStart: make([]int, rows+1)}
+
+	for k := 0; k < len(sorted); {
+		e := sorted[k]
+		if e.Row < 0 || e.Row >= rows || e.Col < 0 || e.Col >= cols {
+			panic("entry out of range, can't build sparse matrix")
+		}
+
+		sum := 0.0
+		for ; k < len(sorted) && sorted[k].Row == e.Row && sorted[k].Col == e.Col; k++ {
+			sum += sorted[k].Value
+		}
+
+		if sum != 0 {
+			m.colIndex = append(m.colIndex, e.Col)
+			m.values = append(m.values, sum)
+			m.rowStart[e.Row+1]++
+		}
+	}
+
+	for i := 0; i < rows; i++ {
+		m.rowStart[i+1] += m.rowStart[i]
+	}
+
+	return m
+}
+
+func NewSparseMatrixFromDense(dense Matrix) SparseMatrix {
+	entries := []SparseEntry{}
+	cols := 0
+
+	for i, row := range dense {
+		cols = len(row)
+		for j, v := range row {
+			if v != 0 {
+				entries = append(entries, SparseEntry{i, j, v})
+			}
+		}
+	}
+
+	return NewSparseMatrix(len(dense), cols, entries)
+}
+
+func (m SparseMatrix) Rows() int {
+	return m.rows
+}
+
+func (m SparseMatrix) Cols() int {
+	return m.cols
+}
+
+func (m SparseMatrix) Size() (int, int) {
+	return m.rows, m.cols
+}
+
+// NonZeros returns the number of stored entries
+func (m SparseMatrix) NonZeros() int {
+	return len(m.values)
+}
+
+func (m SparseMatrix) At(i int, j int) float64 {
+	for k := m.rowStart[i]; k < m.rowStart[i+1]; k++ {
+		if m.colIndex[k] == j {
+			return m.values[k]
+		}
+	}
+
+	return 0
+}
+
+// Entries returns the nonzero entries row by row
+func (m SparseMatrix) Entries() []SparseEntry {
+	result := make([]SparseEntry, 0, len(m.values))
+
+	for i := 0; i < m.rows; i++ {
+		for k := m.rowStart[i]; k < m.rowStart[i+1]; k++ {
+			result = append(result, SparseEntry{i, m.colIndex[k], m.values[k]})
+		}
+	}
+
+	return result
+}
+
+func (m SparseMatrix) Dense() Matrix {
+	result := NewMatrix(m.rows, m.cols)
+
+	for _, e := range m.Entries() {
+		result[e.Row][e.Col] = e.Value
+	}
+
+	return result
+}
+
+func (m SparseMatrix) Transpose() SparseMatrix {
+	entries := m.Entries()
+
+	for k := range entries {
+		entries[k].Row, entries[k].Col = entries[k].Col, entries[k].Row
+	}
+
+	return NewSparseMatrix(m.cols, m.rows, entries)
+}
+
+func (m SparseMatrix) MultiplyVec(v Vector) Vector {
+	if m.cols != len(v) {
+		panic(&DimensionError{"multiply", Shape{m.rows, m.cols}, v.shape()})
+	}
+
+	result := make(Vector, m.rows)
+
+	for i := 0; i < m.rows; i++ {
+		sum := 0.0
+		for k := m.rowStart[i]; k < m.rowStart[i+1]; k++ {
+			sum += m.values[k] * v[m.colIndex[k]]
+		}
+		result[i] = sum
+	}
+
+	return result
+}
+
+func (left SparseMatrix) MultiplyRight(right SparseMatrix) SparseMatrix {
+	if left.cols != right.rows {
+		panic(&DimensionError{"multiply", Shape{left.rows, left.cols}, Shape{right.rows, right.cols}})
+	}
+
+	entries := []SparseEntry{}
+
+	for i := 0; i < left.rows; i++ {
+		row := map[int]float64{}
+
+		for k := left.rowStart[i]; k < left.rowStart[i+1]; k++ {
+			r := left.colIndex[k]
+			for l := right.rowStart[r]; l < right.rowStart[r+1]; l++ {
+				row[right.colIndex[l]] += left.values[k] * right.values[l]
+			}
+		}
+
+		for j, v := range row {
+			entries = append(entries, SparseEntry{i, j, v})
+		}
+	}
+
+	return NewSparseMatrix(left.rows, right.cols, entries)
+}
+
+func (m SparseMatrix) String() string {
+	return m.Dense().String() + "\n(" + strconv.Itoa(m.NonZeros()) + " nonzeros)"
+}
+
+// A candidate pivot must be at least this fraction of the largest entry in
+// its column. Smaller values allow sparser but less stable factorizations.
+const sparsePivotThreshold = 0.1
+
+// SparseLU is the factorization PAQ = LU of a square sparse matrix, where
+// the row and column permutations P and Q are chosen to keep L and U sparse.
+type SparseLU struct {
+	n        int
+	rowPerm  []int           // rowPerm[k] is the row eliminated in step k
+	colPerm  []int           // colPerm[k] is the column eliminated in step k
+	lower    [][]SparseEntry // multipliers of step k, Row is the original row
+	upper    [][]SparseEntry // pivot row of step k, Col is the original column
+	pivots   []float64
+	nonZeros int
+}
+
+// LU factorizes a square matrix with Markowitz pivoting: every step picks the
+// pivot that minimizes (r-1)(c-1), the fill it can cause, where r and c are
+// the entries left in its row and column. Only pivots that are large enough
+// compared to the rest of their column are considered.
+func (m SparseMatrix) LU() (*SparseLU, error) {
+	if m.rows != m.cols {
+		panic(&DimensionError{"factorize", Shape{m.rows, m.cols}, Shape{m.cols, m.rows}})
+	}
+
+	n := m.rows
+
+	// the active submatrix by rows, and which rows use each column
+	rows := make([]map[int]float64, n)
+	colRows := make([]map[int]bool, n)
+	for i := range rows {
+		rows[i] = map[int]float64{}
+		colRows[i] = map[int]bool{}
+	}
+
+	scale := 0.0
+	for _, e := range m.Entries() {
+		rows[e.Row][e.Col] = e.Value
+		colRows[e.Col][e.Row] = true
+		scale = math.Max(scale, math.Abs(e.Value))
+	}
+
+	lu := &SparseLU{
+		n:       n,
+		rowPerm: make([]int, n),
+		colPerm: make([]int, n),
+		lower:   make([][]SparseEntry, n),
+		upper:   make([][]SparseEntry, n),
+		pivots:  make([]float64, n),
+	}
+
+	colDone := make([]bool, n)
+
+	for k := 0; k < n; k++ {
+		r, c := -1, -1
+		best := math.MaxInt
+
+		for j := 0; j < n; j++ {
+			if colDone[j] {
+				continue
+			}
+
+			largest := 0.0
+			for i := range colRows[j] {
+				largest = math.Max(largest, math.Abs(rows[i][j]))
+			}
+
+			if largest <= PivotTolerance*scale {
+				continue
+			}
+
+			for i := range colRows[j] {
+				v := math.Abs(rows[i][j])
+				if v < sparsePivotThreshold*largest {
+					continue
+				}
+
+				cost := (len(rows[i]) - 1) * (len(colRows[j]) - 1)
+				if cost < best || (cost == best && j == c && i < r) {
+					r, c, best = i, j, cost
+				}
+			}
+
+			if best == 0 {
+				// no fill at all, nothing can beat it
+				break
+			}
+		}
+
+		if r < 0 {
+			for j := 0; j < n; j++ {
+				if !colDone[j] {
+					return nil, &SingularMatrixError{Column: j, Pivot: 0}
+				}
+			}
+		}
+
+		pivot := rows[r][c]
+		lu.rowPerm[k], lu.colPerm[k], lu.pivots[k] = r, c, pivot
+
+		for j, v := range rows[r] {
+			lu.upper[k] = append(lu.upper[k], SparseEntry{k, j, v})
+			delete(colRows[j], r)
+		}
+		sortByCol(lu.upper[k])
+
+		targets := []int{}
+		for i := range colRows[c] {
+			targets = append(targets, i)
+		}
+		sort.Ints(targets)
+
+		for _, i := range targets {
+			factor := rows[i][c] / pivot
+			lu.lower[k] = append(lu.lower[k], SparseEntry{i, k, factor})
+
+			for j, v := range rows[r] {
+				if j == c {
+					continue
+				}
+				rows[i][j] -= factor * v
+				colRows[j][i] = true
+			}
+
+			delete(rows[i], c)
+		}
+
+		colRows[c] = map[int]bool{}
+		rows[r] = map[int]float64{}
+		colDone[c] = true
+
+		lu.nonZeros += len(lu.lower[k]) + len(lu.upper[k])
+	}
+
+	return lu, nil
+}
+
+// Solve returns x with Ax = b
+func (lu *SparseLU) Solve(b Vector) Vector {
+	if len(b) != lu.n {
+		panic(&DimensionError{"solve", Shape{lu.n, lu.n}, b.shape()})
+	}
+
+	// forward substitution with L, y is indexed by the original rows
+	y := append(Vector{}, b...)
+	z := make(Vector, lu.n)
+
+	for k := 0; k < lu.n; k++ {
+		z[k] = y[lu.rowPerm[k]]
+		for _, e := range lu.lower[k] {
+			y[e.Row] -= e.Value * z[k]
+		}
+	}
+
+	// back substitution with U, x is indexed by the original columns
+	x := make(Vector, lu.n)
+
+	for k := lu.n - 1; k >= 0; k-- {
+		sum := z[k]
+		for _, e := range lu.upper[k] {
+			if e.Col != lu.colPerm[k] {
+				sum -= e.Value * x[e.Col]
+			}
+		}
+		x[lu.colPerm[k]] = sum / lu.pivots[k]
+	}
+
+	return x
+}
+
+// NonZeros returns the number of entries stored in L and U together, the
+// diagonal counted once. Compared to the nonzeros of the matrix it tells how
+// much fill the factorization caused.
+func (lu *SparseLU) NonZeros() int {
+	return lu.nonZeros
+}
+
+func sortByCol(entries []SparseEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Col < entries[j].Col
+	})
+}
@@ -0,0 +1,185 @@
+package math
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSparse_New(t *testing.T) {
+	m := NewSparseMatrix(2, 3, []SparseEntry{
+		{1, 2, 4},
+		{0, 1, 2},
+		{1, 0, 3},
+		{0, 1, 1},
+		{1, 1, 0},
+	})
+
+	expected := Matrix{
+		{0, 3, 0},
+		{3, 0, 4},
+	}
+
+	assert.Equal(t, expected, m.Dense())
+	assert.Equal(t, 3, m.NonZeros())
+	assert.Equal(t, 4.0, m.At(1, 2))
+	assert.Equal(t, 0.0, m.At(0, 0))
+}
+
+func TestSparse_FromDense(t *testing.T) {
+	dense := Matrix{
+		{1, 0},
+		{0, 0},
+		{5, 6},
+	}
+
+	m := NewSparseMatrixFromDense(dense)
+
+	assert.Equal(t, 3, m.NonZeros())
+	assert.Equal(t, dense, m.Dense())
+	assert.Equal(t, []SparseEntry{{0, 0, 1}, {2, 0, 5}, {2, 1, 6}}, m.Entries())
+}
+
+func TestSparse_Transpose(t *testing.T) {
+	m := NewSparseMatrixFromDense(Matrix{
+		{1, 0, 2},
+		{0, 3, 0},
+	})
+
+	expected := Matrix{
+		{1, 0},
+		{0, 3},
+		{2, 0},
+	}
+
+	assert.Equal(t, expected, m.Transpose().Dense())
+}
+
+func TestSparse_MultiplyVec(t *testing.T) {
+	m := NewSparseMatrixFromDense(Matrix{
+		{1, 0, 2},
+		{0, 3, 0},
+	})
+
+	assert.Equal(t, Vector{7, 6}, m.MultiplyVec(Vector{1, 2, 3}))
+}
+
+func TestSparse_MultiplyRight(t *testing.T) {
+	left := NewSparseMatrixFromDense(Matrix{
+		{1, 3, 5},
+		{2, 4, 6},
+	})
+	right := NewSparseMatrixFromDense(Matrix{
+		{1, 2},
+		{3, 4},
+		{5, 6},
+	})
+
+	expected := Matrix{
+		{35, 44},
+		{44, 56},
+	}
+
+	assert.Equal(t, expected, left.MultiplyRight(right).Dense())
+}
+
+func TestSparse_LUSolve(t *testing.T) {
+	m := NewSparseMatrixFromDense(Matrix{
+		{3, 2, -4},
+		{2, 3, 3},
+		{5, -3, 1},
+	})
+
+	lu, err := m.LU()
+	assert.NoError(t, err)
+
+	got := lu.Solve(Vector{3, 15, 14})
+
+	expected := Vector{3, 1, 2}
+	for i := range expected {
+		assert.InDelta(t, expected[i], got[i], 1e-12)
+	}
+}
+
+func TestSparse_LUPermuted(t *testing.T) {
+	// needs row and column exchanges, the diagonal is zero
+	m := NewSparseMatrixFromDense(Matrix{
+		{0, 0, 2},
+		{0, 4, 1},
+		{1, 0, 0},
+	})
+
+	lu, err := m.LU()
+	assert.NoError(t, err)
+
+	assert.Equal(t, Vector{3, 1, 2}, lu.Solve(Vector{4, 6, 3}))
+}
+
+func TestSparse_LUSingular(t *testing.T) {
+	m := NewSparseMatrixFromDense(Matrix{
+		{1, 2, 0},
+		{2, 4, 0},
+		{0, 0, 1},
+	})
+
+	_, err := m.LU()
+
+	var singular *SingularMatrixError
+	assert.ErrorAs(t, err, &singular)
+}
+
+// An arrow matrix with a full first row and column fills in completely if
+// eliminated in the natural order. Markowitz pivoting leaves it for last.
+func TestSparse_LUFill(t *testing.T) {
+	n := 50
+	entries := []SparseEntry{}
+	b := make(Vector, n)
+
+	for i := 0; i < n; i++ {
+		entries = append(entries, SparseEntry{i, i, 4})
+		if i > 0 {
+			entries = append(entries, SparseEntry{0, i, 1}, SparseEntry{i, 0, 1})
+		}
+		b[i] = float64(i)
+	}
+
+	m := NewSparseMatrix(n, n, entries)
+
+	lu, err := m.LU()
+	assert.NoError(t, err)
+	assert.Equal(t, m.NonZeros(), lu.NonZeros())
+
+	x := lu.Solve(b)
+	residual := m.MultiplyVec(x)
+	for i := range b {
+		assert.InDelta(t, b[i], residual[i], 1e-12)
+	}
+}
+
+func TestSparse_LUStable(t *testing.T) {
+	// the tiny entry would be the sparsest pivot but it's too small
+	m := NewSparseMatrixFromDense(Matrix{
+		{1e-20, 1},
+		{1, 1},
+	})
+
+	lu, err := m.LU()
+	assert.NoError(t, err)
+
+	x := lu.Solve(Vector{1, 2})
+	assert.True(t, math.Abs(x[0]-1) < 1e-12)
+	assert.True(t, math.Abs(x[1]-1) < 1e-12)
+}
+
+func TestSparse_Dimensions(t *testing.T) {
+	m := NewSparseMatrixFromDense(Matrix{{1, 0, 2}, {0, 3, 0}})
+
+	assert.PanicsWithError(t, "can't multiply, dimensions don't match: 2x3 and 2x1", func() { m.MultiplyVec(Vector{1, 2}) })
+	assert.PanicsWithError(t, "can't multiply, dimensions don't match: 2x3 and 2x3", func() { m.MultiplyRight(m) })
+	assert.PanicsWithError(t, "can't factorize, dimensions don't match: 2x3 and 3x2", func() { m.LU() })
+
+	lu, err := NewSparseMatrixFromDense(Matrix{{2, 0}, {0, 3}}).LU()
+	assert.NoError(t, err)
+	assert.PanicsWithError(t, "can't solve, dimensions don't match: 2x2 and 3x1", func() { lu.Solve(Vector{1, 2, 3}) })
+}
@@ -0,0 +1,237 @@
+package math
+
+import (
+	"math"
+	"sort"
+)
+
+// rotations stop once every pair of columns is orthogonal to this precision
+const jacobiTolerance = 1e-15
+
+const maxJacobiSweeps = 100
+
+// SVD is the singular value decomposition A = UΣVᵀ of an m x n matrix. U is
+// m x min(m, n), V is the full n x n orthogonal matrix so that its last
+// columns span the null space, and the singular values are decreasing.
+type SVD struct {
+	rows   int
+	cols   int
+	u      Matrix
+	values Vector
+	v      Matrix
+}
+
+// SVD decomposes a matrix with one-sided Jacobi rotations, which orthogonalize
+// the columns of A pairwise until AV = UΣ. It's slower than bidiagonalization
+// but simple, and accurate for the small singular values that matter for rank.
+func (m Matrix) SVD() *SVD {
+	rows, cols := len(m), 0
+	if rows > 0 {
+		cols = len(m[0])
+	}
+
+	W := m.Copy()
+
+	V := NewMatrix(cols, cols)
+	for j := range V {
+		V[j][j] = 1
+	}
+
+	for sweep := 0; sweep < maxJacobiSweeps; sweep++ {
+		rotated := false
+
+		for p := 0; p < cols; p++ {
+			for q := p + 1; q < cols; q++ {
+				alpha, beta, gamma := 0.0, 0.0, 0.0
+				for i := 0; i < rows; i++ {
+					alpha += W[i][p] * W[i][p]
+					beta += W[i][q] * W[i][q]
+					gamma += W[i][p] * W[i][q]
+				}
+
+				if gamma == 0 || math.Abs(gamma) <= jacobiTolerance*math.Sqrt(alpha*beta) {
+					continue
+				}
+				rotated = true
+
+				// the rotation that makes columns p and q orthogonal
+				zeta := (beta - alpha) / (2 * gamma)
+				t := math.Copysign(1, zeta) / (math.Abs(zeta) + math.Sqrt(1+zeta*zeta))
+				c := 1 / math.Sqrt(1+t*t)
+				s := c * t
+
+				rotate(W, p, q, c, s)
+				rotate(V, p, q, c, s)
+			}
+		}
+
+		if !rotated {
+			break
+		}
+	}
+
+	norms := make(Vector, cols)
+	for j := 0; j < cols; j++ {
+		sum := 0.0
+		for i := 0; i < rows; i++ {
+			sum += W[i][j] * W[i][j]
+		}
+		norms[j] = math.Sqrt(sum)
+	}
+
+	order := make([]int, cols)
+	for j := range order {
+		order[j] = j
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return norms[order[a]] > norms[order[b]]
+	})
+
+	k := min(rows, cols)
+	svd := &SVD{
+		rows:   rows,
+		cols:   cols,
+		u:      NewMatrix(rows, k),
+		values: make(Vector, k),
+		v:      NewMatrix(cols, cols),
+	}
+
+	for j, col := range order {
+		for i := 0; i < cols; i++ {
+			svd.v[i][j] = V[i][col]
+		}
+
+		if j >= k {
+			continue
+		}
+
+		svd.values[j] = norms[col]
+		if norms[col] == 0 {
+			continue
+		}
+		for i := 0; i < rows; i++ {
+			svd.u[i][j] = W[i][col] / norms[col]
+		}
+	}
+
+	return svd
+}
+
+// rotate replaces columns p and q of m with c*p - s*q and s*p + c*q
+func rotate(m Matrix, p int, q int, c float64, s float64) {
+	for i := range m {
+		mp, mq := m[i][p], m[i][q]
+		m[i][p] = c*mp - s*mq
+		m[i][q] = s*mp + c*mq
+	}
+}
+
+// U returns a copy of the left singular vectors as columns. The columns of
+// zero singular values are zero.
+func (svd *SVD) U() Matrix {
+	return svd.u.Copy()
+}
+
+// Values returns the min(m, n) singular values, largest first
+func (svd *SVD) Values() Vector {
+	return append(Vector{}, svd.values...)
+}
+
+// V returns a copy of the right singular vectors as columns
+func (svd *SVD) V() Matrix {
+	return svd.v.Copy()
+}
+
+// Rank counts the singular values that aren't zero within the tolerance,
+// relative to the largest one
+func (svd *SVD) Rank(tolerance float64) int {
+	result := 0
+	for _, sigma := range svd.values {
+		if sigma > tolerance*svd.largest() && sigma > 0 {
+			result++
+		}
+	}
+
+	return result
+}
+
+// NullSpace returns an orthonormal basis of the vectors x with Ax = 0, the
+// right singular vectors beyond the rank
+func (svd *SVD) NullSpace(tolerance float64) []Vector {
+	result := []Vector{}
+
+	for j := svd.Rank(tolerance); j < svd.cols; j++ {
+		x := make(Vector, svd.cols)
+		for i := range x {
+			x[i] = svd.v[i][j]
+		}
+		result = append(result, x)
+	}
+
+	return result
+}
+
+// PseudoInverse returns the n x m Moore-Penrose inverse VΣ⁺Uᵀ, singular values
+// that are zero within the tolerance are left out instead of inverted
+func (svd *SVD) PseudoInverse(tolerance float64) Matrix {
+	result := NewMatrix(svd.cols, svd.rows)
+	rank := svd.Rank(tolerance)
+
+	for i := 0; i < svd.cols; i++ {
+		for j := 0; j < svd.rows; j++ {
+			sum := 0.0
+			for k := 0; k < rank; k++ {
+				sum += svd.v[i][k] * svd.u[j][k] / svd.values[k]
+			}
+			result[i][j] = sum
+		}
+	}
+
+	return result
+}
+
+// ConditionNumber is the ratio of the largest and the smallest singular value,
+// infinite for a rank deficient matrix. Solutions lose about log10 of it
+// digits of precision.
+func (svd *SVD) ConditionNumber() float64 {
+	if len(svd.values) == 0 {
+		return 0
+	}
+
+	smallest := svd.values[len(svd.values)-1]
+	if smallest == 0 {
+		return math.Inf(1)
+	}
+
+	return svd.largest() / smallest
+}
+
+func (svd *SVD) largest() float64 {
+	if len(svd.values) == 0 {
+		return 0
+	}
+
+	return svd.values[0]
+}
+
+// Rank returns the numerical rank of the matrix from its singular values,
+// see SVD.Rank
+func (m Matrix) Rank(tolerance float64) int {
+	return m.SVD().Rank(tolerance)
+}
+
+// NullSpace returns an orthonormal basis of the vectors x with mx = 0, see
+// SVD.NullSpace
+func (m Matrix) NullSpace(tolerance float64) []Vector {
+	return m.SVD().NullSpace(tolerance)
+}
+
+// PseudoInverse returns the Moore-Penrose inverse of the matrix, see
+// SVD.PseudoInverse
+func (m Matrix) PseudoInverse(tolerance float64) Matrix {
+	return m.SVD().PseudoInverse(tolerance)
+}
+
+func (m Matrix) ConditionNumber() float64 {
+	return m.SVD().ConditionNumber()
+}
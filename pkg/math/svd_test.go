@@ -0,0 +1,128 @@
+package math
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reconstruct returns UΣVᵀ
+func reconstruct(svd *SVD) Matrix {
+	U, values, V := svd.U(), svd.Values(), svd.V()
+	result := NewMatrix(len(U), len(V))
+
+	for i := range result {
+		for j := range result[i] {
+			for k, sigma := range values {
+				result[i][j] += U[i][k] * sigma * V[j][k]
+			}
+		}
+	}
+
+	return result
+}
+
+func assertMatrixInDelta(t *testing.T, expected Matrix, actual Matrix, delta float64) {
+	assert.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		assert.InDeltaSlice(t, expected[i], actual[i], delta)
+	}
+}
+
+func TestSVD_Values(t *testing.T) {
+	svd := Matrix{{3, 0}, {4, 5}}.SVD()
+
+	assert.InDeltaSlice(t, Vector{3 * math.Sqrt(5), math.Sqrt(5)}, svd.Values(), 1e-12)
+}
+
+func TestSVD_Reconstruct(t *testing.T) {
+	for _, m := range []Matrix{
+		{{3, 0}, {4, 5}},
+		{{2, 1, -1}, {-3, -1, 2}, {-2, 1, 2}},
+		{{1, 2}, {3, 4}, {5, 6}},
+		{{1, 0, 1}, {0, 1, 1}},
+		{{1, 2}, {2, 4}},
+	} {
+		assertMatrixInDelta(t, m, reconstruct(m.SVD()), 1e-12)
+	}
+}
+
+func TestSVD_Orthogonal(t *testing.T) {
+	V := Matrix{{1, 2, 3}, {4, 5, 6}}.SVD().V()
+
+	for i := range V {
+		for j := range V {
+			dot := 0.0
+			for k := range V {
+				dot += V[k][i] * V[k][j]
+			}
+
+			expected := 0.0
+			if i == j {
+				expected = 1
+			}
+			assert.InDelta(t, expected, dot, 1e-12)
+		}
+	}
+}
+
+func TestSVD_DoesntModify(t *testing.T) {
+	m := Matrix{{1, 2}, {3, 4}}
+
+	m.SVD()
+
+	assert.Equal(t, Matrix{{1, 2}, {3, 4}}, m)
+}
+
+func TestSVD_Rank(t *testing.T) {
+	assert.Equal(t, 0, Matrix{}.Rank(1e-9))
+	assert.Equal(t, 0, Matrix{{0, 0}, {0, 0}}.Rank(1e-9))
+	assert.Equal(t, 2, Matrix{{1, 2}, {3, 4}}.Rank(1e-9))
+	assert.Equal(t, 1, Matrix{{1, 2}, {2, 4}, {3, 6}}.Rank(1e-9))
+	assert.Equal(t, 2, Matrix{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}.Rank(1e-9))
+	assert.Equal(t, 1, Matrix{{1, 1}, {1, 1 + 1e-12}}.Rank(1e-9))
+	assert.Equal(t, 2, Matrix{{1, 0, 1}, {0, 1, 1}}.Rank(1e-9))
+}
+
+func TestSVD_NullSpace(t *testing.T) {
+	null := Matrix{{1, 2}, {2, 4}}.NullSpace(1e-9)
+
+	assert.Len(t, null, 1)
+	// (2, -1) up to sign
+	assert.InDelta(t, 0, null[0][0]+2*null[0][1], 1e-12)
+	assert.InDelta(t, 1, null[0][0]*null[0][0]+null[0][1]*null[0][1], 1e-12)
+
+	null = Matrix{{1, 0, 1}, {0, 1, 1}}.NullSpace(1e-9)
+
+	assert.Len(t, null, 1)
+	assert.InDelta(t, 1/math.Sqrt(3), math.Abs(null[0][0]), 1e-12)
+	assert.InDelta(t, null[0][0], null[0][1], 1e-12)
+	assert.InDelta(t, -null[0][0], null[0][2], 1e-12)
+
+	assert.Empty(t, Matrix{{1, 2}, {3, 4}}.NullSpace(1e-9))
+	assert.Len(t, Matrix{{0, 0}, {0, 0}}.NullSpace(1e-9), 2)
+}
+
+func TestSVD_PseudoInverse(t *testing.T) {
+	assertMatrixInDelta(t,
+		Matrix{{1, 0, 0}, {0, 0.5, 0}},
+		Matrix{{1, 0}, {0, 2}, {0, 0}}.PseudoInverse(1e-9),
+		1e-12)
+
+	assertMatrixInDelta(t,
+		Matrix{{-2, 1}, {1.5, -0.5}},
+		Matrix{{1, 2}, {3, 4}}.PseudoInverse(1e-9),
+		1e-12)
+
+	assertMatrixInDelta(t,
+		Matrix{{0.25, 0.25}, {0.25, 0.25}},
+		Matrix{{1, 1}, {1, 1}}.PseudoInverse(1e-9),
+		1e-12)
+}
+
+func TestSVD_ConditionNumber(t *testing.T) {
+	assert.InDelta(t, 1000, Matrix{{1, 0}, {0, 1e-3}}.ConditionNumber(), 1e-9)
+	assert.InDelta(t, 1, Matrix{{0, 2}, {2, 0}}.ConditionNumber(), 1e-12)
+	assert.True(t, math.IsInf(Matrix{{1, 2}, {2, 4}}.ConditionNumber(), 1))
+}
@@ -2,14 +2,26 @@ package math
 
 import (
 	"fmt"
+	"math"
 	"strings"
 )
 
 type Vector []float64
 
 func (v Vector) Add(v2 Vector) Vector {
+	result, err := v.TryAdd(v2)
+	if err != nil {
+		panic(err)
+	}
+
+	return result
+}
+
+// TryAdd is Add that returns a *DimensionError instead of panicking if the
+// vectors aren't the same length
+func (v Vector) TryAdd(v2 Vector) (Vector, error) {
 	if len(v) != len(v2) {
-		panic("vectors must be the same length")
+		return nil, &DimensionError{"add vectors", v.shape(), v2.shape()}
 	}
 
 	result := make(Vector, len(v))
@@ -18,12 +30,23 @@ func (v Vector) Add(v2 Vector) Vector {
 		result[i] = v[i] + v2[i]
 	}
 
-	return result
+	return result, nil
 }
 
 func (v Vector) Subtract(v2 Vector) Vector {
+	result, err := v.TrySubtract(v2)
+	if err != nil {
+		panic(err)
+	}
+
+	return result
+}
+
+// TrySubtract is Subtract that returns a *DimensionError instead of panicking if the
+// vectors aren't the same length
+func (v Vector) TrySubtract(v2 Vector) (Vector, error) {
 	if len(v) != len(v2) {
-		panic("vectors must be the same length")
+		return nil, &DimensionError{"subtract vectors", v.shape(), v2.shape()}
 	}
 
 	result := make(Vector, len(v))
@@ -32,7 +55,7 @@ func (v Vector) Subtract(v2 Vector) Vector {
 		result[i] = v[i] - v2[i]
 	}
 
-	return result
+	return result, nil
 }
 
 func (v Vector) Multiply(factor float64) Vector {
@@ -55,6 +78,106 @@ func (v Vector) Divide(factor float64) Vector {
 	return result
 }
 
+func (v Vector) Dot(v2 Vector) float64 {
+	if len(v) != len(v2) {
+		panic(&DimensionError{"dot vectors", v.shape(), v2.shape()})
+	}
+
+	result := 0.0
+	for i := range v {
+		result += v[i] * v2[i]
+	}
+
+	return result
+}
+
+// Norm1 is the sum of the absolute values
+func (v Vector) Norm1() float64 {
+	result := 0.0
+	for _, x := range v {
+		result += math.Abs(x)
+	}
+
+	return result
+}
+
+// Norm is the Euclidean length
+func (v Vector) Norm() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+// NormInf is the largest absolute value, 0 for an empty vector
+func (v Vector) NormInf() float64 {
+	result := 0.0
+	for _, x := range v {
+		result = math.Max(result, math.Abs(x))
+	}
+
+	return result
+}
+
+// Cross2 is the z component of the cross product of two 2D vectors, positive
+// if v2 is counterclockwise from v
+func (v Vector) Cross2(v2 Vector) float64 {
+	if len(v) != 2 || len(v2) != 2 {
+		panic(&DimensionError{"cross 2D vectors", v.shape(), v2.shape()})
+	}
+
+	return v[0]*v2[1] - v[1]*v2[0]
+}
+
+func (v Vector) Cross3(v2 Vector) Vector {
+	if len(v) != 3 || len(v2) != 3 {
+		panic(&DimensionError{"cross 3D vectors", v.shape(), v2.shape()})
+	}
+
+	return Vector{
+		v[1]*v2[2] - v[2]*v2[1],
+		v[2]*v2[0] - v[0]*v2[2],
+		v[0]*v2[1] - v[1]*v2[0],
+	}
+}
+
+// Normalize returns the vector scaled to length 1, the zero vector stays zero
+func (v Vector) Normalize() Vector {
+	length := v.Norm()
+	if length == 0 {
+		return make(Vector, len(v))
+	}
+
+	return v.Divide(length)
+}
+
+// Lerp interpolates linearly, t = 0 gives v and t = 1 gives v2
+func (v Vector) Lerp(v2 Vector, t float64) Vector {
+	return v.Add(v2.Subtract(v).Multiply(t))
+}
+
+// Distance is the Euclidean distance between two points
+func (v Vector) Distance(v2 Vector) float64 {
+	return v.Subtract(v2).Norm()
+}
+
+// ApproxEqual reports whether the vectors have the same length and differ by
+// at most tolerance in every element
+func (v Vector) ApproxEqual(v2 Vector, tolerance float64) bool {
+	if len(v) != len(v2) {
+		return false
+	}
+
+	for i := range v {
+		if math.Abs(v[i]-v2[i]) > tolerance {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (v Vector) shape() Shape {
+	return Shape{len(v), 1}
+}
+
 func (v Vector) Format() string {
 	parts := make([]string, len(v))
 	for i, val := range v {
@@ -57,3 +57,76 @@ func TestVector_Format(t *testing.T) {
 
 	assert.Equal(t, expected, got)
 }
+
+func TestVector_TryAdd(t *testing.T) {
+	got, err := Vector{1, 2}.TryAdd(Vector{1, 1})
+
+	assert.NoError(t, err)
+	assert.Equal(t, Vector{2, 3}, got)
+
+	got, err = Vector{1, 2}.TryAdd(Vector{1, 1, 1})
+
+	assert.Equal(t, &DimensionError{"add vectors", Shape{2, 1}, Shape{3, 1}}, err)
+	assert.Nil(t, got)
+}
+
+func TestVector_TrySubtract(t *testing.T) {
+	_, err := Vector{1, 2, 3}.TrySubtract(Vector{1})
+
+	assert.Equal(t, &DimensionError{"subtract vectors", Shape{3, 1}, Shape{1, 1}}, err)
+	assert.Panics(t, func() { Vector{1, 2, 3}.Subtract(Vector{1}) })
+}
+
+func TestVector_Dot(t *testing.T) {
+	assert.Equal(t, 32.0, Vector{1, 2, 3}.Dot(Vector{4, 5, 6}))
+	assert.Equal(t, 0.0, Vector{}.Dot(Vector{}))
+	assert.Panics(t, func() { Vector{1, 2}.Dot(Vector{1}) })
+}
+
+func TestVector_Norms(t *testing.T) {
+	v := Vector{3, -4}
+
+	assert.Equal(t, 7.0, v.Norm1())
+	assert.Equal(t, 5.0, v.Norm())
+	assert.Equal(t, 4.0, v.NormInf())
+	assert.Equal(t, 0.0, Vector{}.NormInf())
+}
+
+func TestVector_Cross2(t *testing.T) {
+	assert.Equal(t, 1.0, Vector{1, 0}.Cross2(Vector{0, 1}))
+	assert.Equal(t, -1.0, Vector{0, 1}.Cross2(Vector{1, 0}))
+	assert.Equal(t, 0.0, Vector{2, 4}.Cross2(Vector{1, 2}))
+	assert.Panics(t, func() { Vector{1, 0, 0}.Cross2(Vector{0, 1, 0}) })
+}
+
+func TestVector_Cross3(t *testing.T) {
+	assert.Equal(t, Vector{0, 0, 1}, Vector{1, 0, 0}.Cross3(Vector{0, 1, 0}))
+	assert.Equal(t, Vector{-3, 6, -3}, Vector{1, 2, 3}.Cross3(Vector{4, 5, 6}))
+	assert.Panics(t, func() { Vector{1, 0}.Cross3(Vector{0, 1}) })
+}
+
+func TestVector_Normalize(t *testing.T) {
+	assert.Equal(t, Vector{0.6, -0.8}, Vector{3, -4}.Normalize())
+	assert.Equal(t, Vector{0, 0}, Vector{0, 0}.Normalize())
+}
+
+func TestVector_Lerp(t *testing.T) {
+	a := Vector{0, 10}
+	b := Vector{4, 20}
+
+	assert.Equal(t, a, a.Lerp(b, 0))
+	assert.Equal(t, b, a.Lerp(b, 1))
+	assert.Equal(t, Vector{1, 12.5}, a.Lerp(b, 0.25))
+}
+
+func TestVector_Distance(t *testing.T) {
+	assert.Equal(t, 5.0, Vector{1, 1}.Distance(Vector{4, 5}))
+}
+
+func TestVector_ApproxEqual(t *testing.T) {
+	v := Vector{1, 2}
+
+	assert.True(t, v.ApproxEqual(Vector{1 + 1e-10, 2}, 1e-9))
+	assert.False(t, v.ApproxEqual(Vector{1.1, 2}, 1e-9))
+	assert.False(t, v.ApproxEqual(Vector{1, 2, 3}, 1e-9))
+}
@@ -1,6 +1,11 @@
 package sketch
 
-import . "equation-solver/pkg/solver"
+import (
+	. "equation-solver/pkg/solver"
+	"fmt"
+	"math"
+	"strings"
+)
 
 type Point struct {
 	Name string
@@ -33,20 +38,42 @@ type Line struct {
 	B    string
 }
 
+type ConstraintKind string
+
+const (
+	DISTANCE ConstraintKind = "DISTANCE"
+	ANGLE    ConstraintKind = "ANGLE"
+	CUSTOM   ConstraintKind = "CUSTOM"
+)
+
+// Constraint records what an equation of the sketch stands for
+type Constraint struct {
+	Index    int // position in the order the constraints were set
+	Kind     ConstraintKind
+	Entities []string // names of the constrained points or lines
+	Value    float64
+}
+
+func (c *Constraint) String() string {
+	return fmt.Sprintf("%s(%s) = %v", strings.ToLower(string(c.Kind)), strings.Join(c.Entities, ", "), c.Value)
+}
+
 type Sketch struct {
-	system     []*Expr
-	points     map[string]*Point
-	lines      map[string]*Line
-	parameters *SystemParameters
+	system      []*Expr
+	constraints []*Constraint // constraints[i] describes system[i]
+	points      map[string]*Point
+	lines       map[string]*Line
+	parameters  *SystemParameters
 }
 
 func NewSketch() *Sketch {
 
 	return &Sketch{
-		system:     []*Expr{},
-		points:     map[string]*Point{},
-		lines:      map[string]*Line{},
-		parameters: &SystemParameters{},
+		system:      []*Expr{},
+		constraints: []*Constraint{},
+		points:      map[string]*Point{},
+		lines:       map[string]*Line{},
+		parameters:  &SystemParameters{},
 	}
 }
 
@@ -77,15 +104,143 @@ func (s *Sketch) SetDistance(A string, B string, d float64) {
 		Add(a.Y.Subtract(b.Y).Square()).
 		Subtract(Number(d).Square())
 
+	s.addConstraint(e, DISTANCE, []string{A, B}, d)
+}
+
+func (s *Sketch) addConstraint(e *Expr, kind ConstraintKind, entities []string, value float64) {
+	c := &Constraint{len(s.constraints), kind, entities, value}
+
 	s.system = append(s.system, e)
+	s.constraints = append(s.constraints, c)
+}
+
+func (s *Sketch) Constraints() []*Constraint {
+	return s.constraints
+}
+
+// SetAngle constrains the angle from line A to line B, counterclockwise in
+// radians. Lines have no direction, so the angle plus π satisfies it too.
+func (s *Sketch) SetAngle(A string, B string, angle float64) {
+	ax, ay := s.direction(s.lines[A])
+	bx, by := s.direction(s.lines[B])
+
+	// |a||b|sin(θ - angle) = cross*cos(angle) - dot*sin(angle), which unlike
+	// atan2(cross, dot) - angle doesn't jump at ±π
+	cross := ax.Multiply(by).Subtract(ay.Multiply(bx))
+	dot := ax.Multiply(bx).Add(ay.Multiply(by))
+
+	e := cross.Multiply(Number(math.Cos(angle))).
+		Subtract(dot.Multiply(Number(math.Sin(angle))))
+
+	s.addConstraint(e, ANGLE, []string{A, B}, angle)
+}
+
+// direction returns the vector from the first point of the line to the second
+func (s *Sketch) direction(l *Line) (*Expr, *Expr) {
+	a := s.points[l.A]
+	b := s.points[l.B]
+
+	return b.X.Subtract(a.X), b.Y.Subtract(a.Y)
+}
+
+// SetCustom constrains points with a residual computed by Go code, like a
+// clearance check or a lookup table. f gets the x and y of each point in order
+// and is 0 where the constraint holds. Its derivatives are estimated by the
+// solver with central differences.
+func (s *Sketch) SetCustom(name string, points []string, f func(coordinates []float64) float64) {
+	params := []string{}
+	fixed := make([]float64, 2*len(points)) // coordinates of origins
+	at := make([]int, 2*len(points))        // index of a coordinate in params, -1 for origins
+
+	for i, p := range points {
+		for k, c := range []*Expr{s.points[p].X, s.points[p].Y} {
+			at[2*i+k] = -1
+			if c.Type == PARAMETER {
+				at[2*i+k] = len(params)
+				params = append(params, c.Name)
+			} else {
+				fixed[2*i+k] = c.Value
+			}
+		}
+	}
+
+	e := Func(name, params, func(values []float64) float64 {
+		coordinates := make([]float64, len(at))
+		for i, j := range at {
+			coordinates[i] = fixed[i]
+			if j >= 0 {
+				coordinates[i] = values[j]
+			}
+		}
+
+		return f(coordinates)
+	})
+
+	s.addConstraint(e, CUSTOM, points, 0)
+}
+
+func (s *Sketch) SatisfyConstraints(options SolverOptions) SolveReport {
+	return SolveSystem(s.system, s.parameters, options)
+}
+
+// DOFReport tells how far a sketch is from being fully constrained
+type DOFReport struct {
+	Free   int            // degrees of freedom of the whole sketch
+	Points map[string]int // degrees of freedom left for each point, from 0 to 2
 }
 
-func (s *Sketch) SetAngle(A Line, B Line, angle float64) {
-	panic("todo")
+func (r DOFReport) FullyConstrained() bool {
+	return r.Free == 0
 }
 
-func (s *Sketch) SatisfyConstraints() {
-	SolveSystem(s.system, s.parameters)
+// DegreesOfFreedom analyses the Jacobian of the constraints at the current
+// coordinates, call it after SatisfyConstraints.
+func (s *Sketch) DegreesOfFreedom() DOFReport {
+	report := DOFReport{
+		Free:   DegreesOfFreedom(s.system, s.parameters),
+		Points: map[string]int{},
+	}
+
+	for name, p := range s.points {
+		if p.X.Type != PARAMETER {
+			report.Points[name] = 0
+			continue
+		}
+
+		report.Points[name] = ParameterFreedom(s.system, s.parameters, []string{p.X.Name, p.Y.Name})
+	}
+
+	return report
+}
+
+// ConstraintIssue is a constraint that doesn't constrain anything the
+// constraints it depends on don't already
+type ConstraintIssue struct {
+	Constraint  *Constraint
+	DependsOn   []*Constraint
+	Conflicting bool // it can't hold together with DependsOn, otherwise it's redundant
+}
+
+// Diagnose names the redundant and the conflicting constraints. The
+// coordinates of the sketch are left unchanged.
+func (s *Sketch) Diagnose(options SolverOptions) []ConstraintIssue {
+	result := []ConstraintIssue{}
+
+	for _, d := range Diagnose(s.system, s.parameters, options) {
+		issue := ConstraintIssue{
+			Constraint:  s.constraints[d.Equation],
+			DependsOn:   []*Constraint{},
+			Conflicting: d.Conflicting,
+		}
+
+		for _, i := range d.DependsOn {
+			issue.DependsOn = append(issue.DependsOn, s.constraints[i])
+		}
+
+		result = append(result, issue)
+	}
+
+	return result
 }
 
 func (s *Sketch) PrintParams() {
@@ -95,3 +250,9 @@ func (s *Sketch) PrintParams() {
 func (s *Sketch) GetParam(name string) float64 {
 	return s.parameters.Get(name)
 }
+
+// LookupParam is GetParam that returns an *UnknownParameterError instead of
+// panicking for a name that isn't in the sketch
+func (s *Sketch) LookupParam(name string) (float64, error) {
+	return s.parameters.Lookup(name)
+}
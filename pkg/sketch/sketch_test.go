@@ -1,58 +1,373 @@
 package sketch
 
 import (
+	. "equation-solver/pkg/math"
+	. "equation-solver/pkg/solver"
 	. "equation-solver/pkg/utils"
+	"math"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
+// corpus are sketches with a free point A and where the solver moves it,
+// shared by the tests below and the benchmark of the solver methods
+var corpus = []struct {
+	name  string
+	build func() *Sketch
+	Ax    float64
+	Ay    float64
+}{
+	{"Distance", func() *Sketch {
+		s := NewSketch()
+		s.AddOrigin("O1", 0, 0)
+		s.AddOrigin("O2", 10, 0)
+		s.AddPoint("A", 5, 3)
+		s.SetDistance("O1", "A", 7)
+		s.SetDistance("O2", "A", 7)
+		return s
+	}, 5, 4.898979485566356},
+	{"Distance2", func() *Sketch {
+		s := NewSketch()
+		s.AddOrigin("O1", 0, 0)
+		s.AddOrigin("O2", 10, 0)
+		s.AddPoint("A", 5, 3)
+		s.SetDistance("O1", "A", 5)
+		s.SetDistance("O2", "A", 11.18)
+		return s
+	}, 0, 5},
+	{"Distance3", func() *Sketch {
+		s := NewSketch()
+		s.AddOrigin("O1", 100, 0)
+		s.AddOrigin("O2", 0, 100)
+		s.AddPoint("A", 200, 150)
+		s.SetDistance("O1", "A", 100)
+		s.SetDistance("O2", "A", 100)
+		return s
+	}, 100, 100},
+	// A starts almost on the line through the origins, where the Jacobian
+	// is numerically singular
+	{"NearlyCollinear", func() *Sketch {
+		s := NewSketch()
+		s.AddOrigin("O1", 0, 0)
+		s.AddOrigin("O2", 10, 0)
+		s.AddPoint("A", 3, 1e-30)
+		s.SetDistance("O1", "A", 7)
+		s.SetDistance("O2", "A", 7)
+		return s
+	}, 5, 4.898979485566356},
+}
+
+// sketchOf builds the sketch of the corpus with the name
+func sketchOf(t *testing.T, name string) (*Sketch, float64, float64) {
+	for _, c := range corpus {
+		if c.name == name {
+			return c.build(), c.Ax, c.Ay
+		}
+	}
+
+	t.Fatalf("no sketch %s in the corpus", name)
+	return nil, 0, 0
+}
+
+// assertSolves checks that the sketch of the corpus converges to its solution
+func assertSolves(t *testing.T, name string, options SolverOptions) {
+	s, x, y := sketchOf(t, name)
+
+	report := s.SatisfyConstraints(options)
+
+	assert.Equal(t, CONVERGED, report.Result)
+	AssertAlmost(t, s.GetParam("Ax"), x)
+	AssertAlmost(t, s.GetParam("Ay"), y)
+}
+
 func TestSketch_Distance(t *testing.T) {
+	assertSolves(t, "Distance", DefaultSolverOptions())
+}
+
+func TestSketch_Distance2(t *testing.T) {
+	assertSolves(t, "Distance2", DefaultSolverOptions())
+}
+
+func TestSketch_Distance3(t *testing.T) {
+	assertSolves(t, "Distance3", DefaultSolverOptions())
+}
+
+func TestSketch_Distance3Damped(t *testing.T) {
+	assertSolves(t, "Distance3", SolverOptions{Method: LEVENBERG_MARQUARDT})
+}
+
+// Newton gives up where the Jacobian is singular, the damped method moves A
+// off the line
+func TestSketch_NearlyCollinearStart(t *testing.T) {
+	newton, _, _ := sketchOf(t, "NearlyCollinear")
+	report := newton.SatisfyConstraints(SolverOptions{Method: NEWTON})
+	assert.Equal(t, SINGULAR, report.Result)
+
+	assertSolves(t, "NearlyCollinear", SolverOptions{Method: LEVENBERG_MARQUARDT})
+}
+
+// A only has a distance to O1, the smallest move keeps its direction
+func TestSketch_PartiallyConstrained(t *testing.T) {
+	s := NewSketch()
+
+	s.AddOrigin("O1", 0, 0)
+
+	s.AddPoint("A", 3, 4)
+	s.AddPoint("B", 1, 1)
+
+	s.SetDistance("O1", "A", 10)
+
+	report := s.SatisfyConstraints(DefaultSolverOptions())
+
+	assert.Equal(t, CONVERGED, report.Result)
+	AssertAlmost(t, s.GetParam("Ax"), 6)
+	AssertAlmost(t, s.GetParam("Ay"), 8)
+	assert.Equal(t, 1.0, s.GetParam("Bx"))
+	assert.Equal(t, 1.0, s.GetParam("By"))
+}
+
+// A sits on O1, the distance between them has no direction to move in
+func TestSketch_CoincidentPoints(t *testing.T) {
+	s := NewSketch()
+
+	s.AddOrigin("O1", 0, 0)
+	s.AddOrigin("O2", 10, 0)
+
+	s.AddPoint("A", 0, 0)
+
+	s.SetDistance("O1", "A", 5)
+	s.SetDistance("O2", "A", 7)
+
+	report := s.SatisfyConstraints(DefaultSolverOptions())
+
+	var singular *SingularMatrixError
+	assert.Equal(t, SINGULAR, report.Result)
+	assert.ErrorAs(t, report.Err, &singular)
+	assert.Equal(t, 0.0, s.GetParam("Ax"))
+	assert.Equal(t, 0.0, s.GetParam("Ay"))
+}
+
+func TestSketch_DegreesOfFreedom(t *testing.T) {
 	s := NewSketch()
 
 	s.AddOrigin("O1", 0, 0)
 	s.AddOrigin("O2", 10, 0)
 
 	s.AddPoint("A", 5, 3)
+	s.AddPoint("B", 1, 1)
+	s.AddPoint("C", 20, 1)
 
 	s.SetDistance("O1", "A", 7)
 	s.SetDistance("O2", "A", 7)
+	s.SetDistance("O2", "C", 8)
 
-	s.SatisfyConstraints()
+	s.SatisfyConstraints(DefaultSolverOptions())
 
-	println(s.parameters.Format())
+	dof := s.DegreesOfFreedom()
 
-	AssertAlmost(t, s.GetParam("Ax"), 5)
-	AssertAlmost(t, s.GetParam("Ay"), 4.898979485566356)
+	assert.Equal(t, 3, dof.Free)
+	assert.False(t, dof.FullyConstrained())
+	assert.Equal(t, map[string]int{"O1": 0, "O2": 0, "A": 0, "B": 2, "C": 1}, dof.Points)
 }
 
-func TestSketch_Distance2(t *testing.T) {
+func TestSketch_FullyConstrained(t *testing.T) {
+	s := corpus[0].build()
+
+	s.SatisfyConstraints(DefaultSolverOptions())
+
+	dof := s.DegreesOfFreedom()
+
+	assert.True(t, dof.FullyConstrained())
+	assert.Equal(t, 0, dof.Points["A"])
+}
+
+func threeDistances(third float64) *Sketch {
 	s := NewSketch()
 
 	s.AddOrigin("O1", 0, 0)
 	s.AddOrigin("O2", 10, 0)
+	s.AddOrigin("O3", 5, 10)
 
 	s.AddPoint("A", 5, 3)
 
-	s.SetDistance("O1", "A", 5)
-	s.SetDistance("O2", "A", 11.18)
+	s.SetDistance("O1", "A", 7)
+	s.SetDistance("O2", "A", 7)
+	s.SetDistance("O3", "A", third)
+
+	return s
+}
 
-	s.SatisfyConstraints()
+func TestSketch_DiagnoseRedundant(t *testing.T) {
+	s := threeDistances(10 - 4.898979485566356)
 
+	issues := s.Diagnose(DefaultSolverOptions())
+
+	constraints := s.Constraints()
+	assert.Equal(t, []ConstraintIssue{{
+		Constraint:  constraints[2],
+		DependsOn:   []*Constraint{constraints[0], constraints[1]},
+		Conflicting: false,
+	}}, issues)
+	assert.Equal(t, 5.0, s.GetParam("Ax"))
+	assert.Equal(t, 3.0, s.GetParam("Ay"))
+}
+
+func TestSketch_DiagnoseConflicting(t *testing.T) {
+	s := threeDistances(3)
+
+	issues := s.Diagnose(DefaultSolverOptions())
+
+	assert.Equal(t, 1, len(issues))
+	assert.True(t, issues[0].Conflicting)
+	assert.Equal(t, "distance(O3, A) = 3", issues[0].Constraint.String())
+	assert.Equal(t, 2, len(issues[0].DependsOn))
+}
+
+func TestSketch_DiagnoseNothing(t *testing.T) {
+	s := corpus[0].build()
+
+	assert.Equal(t, []ConstraintIssue{}, s.Diagnose(DefaultSolverOptions()))
+}
+
+// two triangles that share no point are solved one after the other
+func TestSketch_Clusters(t *testing.T) {
+	s := NewSketch()
+
+	s.AddOrigin("O1", 0, 0)
+	s.AddOrigin("O2", 10, 0)
+	s.AddOrigin("O3", 100, 0)
+	s.AddOrigin("O4", 110, 0)
+
+	s.AddPoint("A", 5, 3)
+	s.AddPoint("B", 105, 3)
+
+	s.SetDistance("O1", "A", 7)
+	s.SetDistance("O3", "B", 7)
+	s.SetDistance("O2", "A", 7)
+	s.SetDistance("O4", "B", 7)
+
+	report := s.SatisfyConstraints(DefaultSolverOptions())
+
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.Equal(t, 2, len(report.Components))
+	assert.Equal(t, []int{0, 2}, report.Components[0].Equations)
+	assert.Equal(t, []string{"Bx", "By"}, report.Components[1].Parameters)
+	AssertAlmost(t, s.GetParam("Ay"), 4.898979485566356)
+	AssertAlmost(t, s.GetParam("Bx"), 105)
+	AssertAlmost(t, s.GetParam("By"), 4.898979485566356)
+}
+
+var methods = []SolverMethod{NEWTON, LEVENBERG_MARQUARDT, DOGLEG}
+
+func TestSketch_Dogleg(t *testing.T) {
+	for _, c := range corpus {
+		s := c.build()
+
+		report := s.SatisfyConstraints(SolverOptions{Method: DOGLEG})
+
+		assert.Equal(t, CONVERGED, report.Result, c.name)
+		assert.Equal(t, report.Iterations, len(report.TrustRadii), c.name)
+	}
+}
+
+func BenchmarkSketch_Methods(b *testing.B) {
+	for _, c := range corpus {
+		for _, m := range methods {
+			b.Run(c.name+"/"+string(m), func(b *testing.B) {
+				iterations := 0
+				failures := 0
+
+				for i := 0; i < b.N; i++ {
+					report := c.build().SatisfyConstraints(SolverOptions{Method: m})
+
+					iterations += report.Iterations
+					if report.Result != CONVERGED {
+						failures++
+					}
+				}
+
+				b.ReportMetric(float64(iterations)/float64(b.N), "iterations/op")
+				b.ReportMetric(float64(failures)/float64(b.N), "failures/op")
+			})
+		}
+	}
+}
+
+func TestSketch_LookupParam(t *testing.T) {
+	s := NewSketch()
+	s.AddPoint("A", 5, 3)
+
+	value, err := s.LookupParam("Ay")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, value)
+
+	_, err = s.LookupParam("Bx")
+
+	assert.Equal(t, &UnknownParameterError{Name: "Bx"}, err)
+}
+
+func TestSketch_Angle(t *testing.T) {
+	s := NewSketch()
+
+	s.AddOrigin("O", 0, 0)
+	s.AddOrigin("X", 10, 0)
+	s.AddPoint("A", 3, 4)
+
+	s.AddLine("OX", "O", "X")
+	s.AddLine("OA", "O", "A")
+
+	s.SetDistance("O", "A", 5)
+	s.SetAngle("OX", "OA", math.Pi/2)
+
+	report := s.SatisfyConstraints(DefaultSolverOptions())
+
+	assert.Equal(t, CONVERGED, report.Result)
 	AssertAlmost(t, s.GetParam("Ax"), 0)
 	AssertAlmost(t, s.GetParam("Ay"), 5)
 }
 
-func TestSketch_Distance3(t *testing.T) {
+func TestSketch_Angle45(t *testing.T) {
 	s := NewSketch()
 
-	s.AddOrigin("O1", 100, 0)
-	s.AddOrigin("O2", 0, 100)
+	s.AddOrigin("O", 0, 0)
+	s.AddOrigin("X", 1, 0)
+	s.AddPoint("A", 4, 1)
 
-	s.AddPoint("A", 200, 150)
+	s.AddLine("OX", "O", "X")
+	s.AddLine("OA", "O", "A")
 
-	s.SetDistance("O1", "A", 100)
-	s.SetDistance("O2", "A", 100)
+	s.SetDistance("O", "A", math.Sqrt2)
+	s.SetAngle("OX", "OA", math.Pi/4)
 
-	s.SatisfyConstraints()
+	report := s.SatisfyConstraints(DefaultSolverOptions())
 
-	println(s.parameters.Format())
-}
\ No newline at end of file
+	assert.Equal(t, CONVERGED, report.Result)
+	AssertAlmost(t, s.GetParam("Ax"), 1)
+	AssertAlmost(t, s.GetParam("Ay"), 1)
+	assert.Equal(t, "angle(OX, OA) = 0.7853981633974483", s.Constraints()[1].String())
+}
+
+func TestSketch_Custom(t *testing.T) {
+	for _, mode := range []JacobianMode{SYMBOLIC, AUTODIFF, FINITE_DIFFERENCE} {
+		s := NewSketch()
+
+		s.AddOrigin("O", 0, 0)
+		s.AddOrigin("X", 10, 0)
+		s.AddPoint("A", 3, 3)
+
+		// A is 5 away from O, checked by Go code
+		s.SetCustom("clearance", []string{"O", "A"}, func(c []float64) float64 {
+			return math.Hypot(c[2]-c[0], c[3]-c[1]) - 5
+		})
+		s.SetDistance("X", "A", math.Sqrt(65))
+
+		report := s.SatisfyConstraints(SolverOptions{Jacobian: mode})
+
+		assert.Equal(t, CONVERGED, report.Result, mode)
+		AssertAlmost(t, s.GetParam("Ax"), 3)
+		AssertAlmost(t, s.GetParam("Ay"), 4)
+		assert.Equal(t, "custom(O, A) = 0", s.Constraints()[0].String())
+	}
+}
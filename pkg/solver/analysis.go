@@ -0,0 +1,132 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	"math"
+)
+
+// entries smaller than this, relative to the largest one, count as zero when
+// computing the numerical rank of a Jacobian
+const rankTolerance = 1e-9
+
+// DegreesOfFreedom returns in how many independent directions the parameters
+// can still move without violating the equations. It's the number of
+// parameters minus the rank of the Jacobian at the current parameter values,
+// so it's only meaningful once the system is solved.
+func DegreesOfFreedom(equationSystem []*Expr, params *SystemParameters) int {
+	J := jacobianAt(equationSystem, params, SYMBOLIC)
+
+	return len(params.list) - rank(J, rankTolerance)
+}
+
+// ParameterFreedom returns how many of the degrees of freedom move the named
+// parameters. For the two coordinates of a point it's 0 if the point is
+// fixed, 1 if it can slide along a curve and 2 if it's free.
+//
+// The freedom is the dimension of the null space of J projected onto the
+// named parameters, which is rank([J; E]) - rank(J) where the rows of E
+// select the named parameters.
+func ParameterFreedom(equationSystem []*Expr, params *SystemParameters, names []string) int {
+	J := jacobianAt(equationSystem, params, SYMBOLIC)
+	withSelection := append(Matrix{}, J...)
+
+	for _, name := range names {
+		row := make(Vector, len(params.list))
+		for i, p := range params.list {
+			if p.name == name {
+				row[i] = 1
+			}
+		}
+		withSelection = append(withSelection, row)
+	}
+
+	return rank(withSelection, rankTolerance) - rank(J, rankTolerance)
+}
+
+// jacobianAt evaluates the Jacobian at the current parameter values the way
+// the solver would, the result has a row for each equation even if there are
+// no parameters
+func jacobianAt(equationSystem []*Expr, params *SystemParameters, mode JacobianMode) Matrix {
+	if len(equationSystem) == 0 {
+		return Matrix{}
+	}
+
+	return compileSystem(equationSystem, params, mode).jacobian(params)
+}
+
+// rank returns the number of linearly independent rows of m, the singular
+// values that aren't zero within the tolerance, relative to the largest one.
+// Unlike elimination, the SVD finds nearly dependent rows reliably.
+func rank(m Matrix, tolerance float64) int {
+	return m.Rank(tolerance)
+}
+
+// Dependency is an equation whose Jacobian row is a linear combination of the
+// rows of other equations, so it doesn't constrain anything new
+type Dependency struct {
+	Equation    int   // index of the dependent equation
+	DependsOn   []int // the equations it's a combination of
+	Conflicting bool  // the equations can't all hold at the same time
+}
+
+// Diagnose finds the redundant and the conflicting equations of a system.
+//
+// It first solves a copy of the parameters with Levenberg-Marquardt, which
+// ends in a least squares compromise if the equations conflict, params are
+// left unchanged. Then it goes through the equations in order and keeps the
+// ones whose Jacobian row is independent of the rows kept so far. Every other
+// equation is dependent: redundant if it and the equations it depends on all
+// hold within the residual tolerance, conflicting if they don't.
+func Diagnose(equationSystem []*Expr, params *SystemParameters, options SolverOptions) []Dependency {
+	options = options.withDefaults()
+	options.Method = LEVENBERG_MARQUARDT
+
+	trial := params.clone()
+	SolveSystem(equationSystem, trial, options)
+
+	system := compileSystem(equationSystem, trial, options.Jacobian)
+	J := system.jacobian(trial)
+	F := system.residuals(trial)
+
+	result := []Dependency{}
+	independent := []int{}
+
+	for i := range equationSystem {
+		if !dependsOn(J, i, independent) {
+			independent = append(independent, i)
+			continue
+		}
+
+		dependency := Dependency{Equation: i, DependsOn: []int{}}
+
+		// an equation takes part if the row is independent without it
+		for k, j := range independent {
+			others := append(append([]int{}, independent[:k]...), independent[k+1:]...)
+			if !dependsOn(J, i, others) {
+				dependency.DependsOn = append(dependency.DependsOn, j)
+			}
+		}
+
+		for _, j := range append([]int{i}, dependency.DependsOn...) {
+			if math.Abs(F[j]) > options.ResidualTolerance {
+				dependency.Conflicting = true
+			}
+		}
+
+		result = append(result, dependency)
+	}
+
+	return result
+}
+
+// dependsOn tells whether row i of J is a linear combination of the rows
+func dependsOn(J Matrix, i int, rows []int) bool {
+	m := Matrix{}
+	for _, j := range rows {
+		m = append(m, J[j])
+	}
+
+	before := rank(m, rankTolerance)
+
+	return rank(append(m, J[i]), rankTolerance) == before
+}
@@ -0,0 +1,122 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalysis_Rank(t *testing.T) {
+	assert.Equal(t, 0, rank(Matrix{}, rankTolerance))
+	assert.Equal(t, 0, rank(Matrix{{0, 0}, {0, 0}}, rankTolerance))
+	assert.Equal(t, 2, rank(Matrix{{1, 2}, {3, 4}}, rankTolerance))
+	assert.Equal(t, 1, rank(Matrix{{1, 2}, {2, 4}, {3, 6}}, rankTolerance))
+	assert.Equal(t, 2, rank(Matrix{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}, rankTolerance))
+	assert.Equal(t, 1, rank(Matrix{{1, 1}, {1, 1 + 1e-12}}, rankTolerance))
+}
+
+func TestAnalysis_RankKeepsMatrix(t *testing.T) {
+	m := Matrix{{0, 1}, {1, 0}}
+
+	rank(m, rankTolerance)
+
+	assert.Equal(t, Matrix{{0, 1}, {1, 0}}, m)
+}
+
+func TestAnalysis_DegreesOfFreedom(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 3)
+	p.Add("y", 4)
+	p.Add("z", 1)
+
+	// x and y on a circle, z free
+	sys := []*Expr{
+		Param("x").Square().Add(Param("y").Square()).Subtract(Number(25)),
+	}
+
+	assert.Equal(t, 2, DegreesOfFreedom(sys, p))
+	assert.Equal(t, 1, ParameterFreedom(sys, p, []string{"x", "y"}))
+	assert.Equal(t, 1, ParameterFreedom(sys, p, []string{"z"}))
+	assert.Equal(t, 2, ParameterFreedom(sys, p, []string{"x", "y", "z"}))
+
+	// fixing y leaves x one of two isolated points
+	sys = append(sys, Param("y").Subtract(Number(4)))
+
+	assert.Equal(t, 1, DegreesOfFreedom(sys, p))
+	assert.Equal(t, 0, ParameterFreedom(sys, p, []string{"x", "y"}))
+}
+
+func TestAnalysis_DegreesOfFreedomNoEquations(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 3)
+	p.Add("y", 4)
+
+	assert.Equal(t, 2, DegreesOfFreedom([]*Expr{}, p))
+	assert.Equal(t, 1, ParameterFreedom([]*Expr{}, p, []string{"y"}))
+}
+
+func TestAnalysis_DiagnoseRedundant(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 1)
+	p.Add("y", 1)
+
+	sys := []*Expr{
+		Param("x").Subtract(Number(2)),
+		Param("y").Subtract(Number(3)),
+		Param("x").Add(Param("y")).Subtract(Number(5)),
+	}
+
+	got := Diagnose(sys, p, DefaultSolverOptions())
+
+	assert.Equal(t, []Dependency{{Equation: 2, DependsOn: []int{0, 1}, Conflicting: false}}, got)
+	assert.Equal(t, 1.0, p.Get("x"))
+	assert.Equal(t, 1.0, p.Get("y"))
+}
+
+func TestAnalysis_DiagnoseConflicting(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 1)
+	p.Add("y", 1)
+
+	sys := []*Expr{
+		Param("x").Subtract(Number(2)),
+		Param("y").Subtract(Number(3)),
+		Param("x").Subtract(Number(4)),
+	}
+
+	got := Diagnose(sys, p, DefaultSolverOptions())
+
+	assert.Equal(t, []Dependency{{Equation: 2, DependsOn: []int{0}, Conflicting: true}}, got)
+}
+
+func TestAnalysis_DiagnoseIndependent(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 1)
+	p.Add("y", 1)
+
+	sys := []*Expr{
+		Param("x").Square().Add(Param("y")),
+		Param("y").Square().Add(Param("x")).Subtract(Number(1)),
+	}
+
+	assert.Equal(t, []Dependency{}, Diagnose(sys, p, DefaultSolverOptions()))
+}
+
+func TestAnalysis_DiagnoseJacobianModes(t *testing.T) {
+	sys := []*Expr{
+		Param("x").Subtract(Number(2)),
+		Param("y").Subtract(Number(3)),
+		Param("x").Add(Param("y")).Subtract(Number(5)),
+	}
+
+	for _, mode := range []JacobianMode{SYMBOLIC, AUTODIFF, FINITE_DIFFERENCE} {
+		p := &SystemParameters{}
+		p.Add("x", 1)
+		p.Add("y", 1)
+
+		got := Diagnose(sys, p, SolverOptions{Jacobian: mode})
+
+		assert.Equal(t, []Dependency{{Equation: 2, DependsOn: []int{0, 1}, Conflicting: false}}, got, mode)
+	}
+}
@@ -0,0 +1,210 @@
+package solver
+
+import (
+	"math"
+)
+
+// dualTape evaluates an equation and its gradient in one forward pass. Every
+// node carries its value and its derivatives by the parameters the equation
+// depends on, which are propagated with the chain rule as the node is
+// computed, instead of building a derivative tree for each parameter.
+type dualTape struct {
+	instructions []instruction // a parameter reads its local index from left, a function calls[left]
+	calls        []call        // with the local indices of the parameters
+	columns      []int         // columns[k] is the index in params.list of local parameter k
+	values       []float64
+	gradients    []float64 // gradients[i*n+k] is the derivative of node i by local parameter k
+
+	at    []float64 // the parameter values of the last run
+	valid bool
+	runs  int
+}
+
+// compileDual compiles an equation for the named parameters, found at columns
+// of the parameter list. Other parameters are constant 0, like in Eval.
+func compileDual(e *Expr, names []string, columns []int) *dualTape {
+	g := newDAG()
+	g.intern(e)
+
+	local := map[string]int32{}
+	for k, name := range names {
+		local[name] = int32(k)
+	}
+
+	t := &dualTape{
+		instructions: make([]instruction, len(g.nodes)),
+		columns:      columns,
+		values:       make([]float64, len(g.nodes)),
+		gradients:    make([]float64, len(g.nodes)*len(names)),
+		at:           make([]float64, len(names)),
+	}
+
+	for i, n := range g.nodes {
+		in := instruction{op: opcodes[n.Type], left: int32(n.left), right: int32(n.right), value: n.value}
+
+		switch n.Type {
+		case PARAMETER:
+			in.left = lookup(local, n.name)
+		case FUNCTION:
+			in.left = int32(len(t.calls))
+			t.calls = append(t.calls, newCall(n.fn, local))
+		}
+
+		t.instructions[i] = in
+	}
+
+	return t
+}
+
+// run returns the gradient of the equation at the current parameter values,
+// indexed like columns, value returns the equation. Like the tape, it only
+// computes them again once the parameter values change, so the residual and
+// the row of the Jacobian at the same point cost one pass.
+func (t *dualTape) run(params *SystemParameters) []float64 {
+	n := len(t.columns)
+	last := len(t.instructions) - 1
+
+	if t.changed(params) {
+		t.forward(params)
+	}
+
+	return t.gradients[last*n : (last+1)*n]
+}
+
+func (t *dualTape) value() float64 {
+	return t.values[len(t.values)-1]
+}
+
+// changed records the parameter values and reports whether they differ from
+// the ones of the last run
+func (t *dualTape) changed(params *SystemParameters) bool {
+	changed := !t.valid
+	for k, j := range t.columns {
+		if x := params.list[j].value; x != t.at[k] {
+			t.at[k] = x
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// forward computes the values and gradients of every node
+func (t *dualTape) forward(params *SystemParameters) {
+	n := len(t.columns)
+	v := t.values
+
+	for i, in := range t.instructions {
+		g := t.gradients[i*n : (i+1)*n]
+
+		var l, r float64
+		var gl, gr []float64
+		if in.left >= 0 && in.op != opParameter && in.op != opFunction {
+			l, gl = v[in.left], t.gradients[int(in.left)*n:int(in.left+1)*n]
+		}
+		if in.right >= 0 {
+			r, gr = v[in.right], t.gradients[int(in.right)*n:int(in.right+1)*n]
+		}
+
+		switch in.op {
+		case opConstant:
+			v[i] = in.value
+			clear(g)
+		case opParameter:
+			clear(g)
+			v[i] = 0
+			if in.left >= 0 {
+				v[i] = params.list[t.columns[in.left]].value
+				g[in.left] = 1
+			}
+		case opAdd:
+			v[i] = l + r
+			for k := range g {
+				g[k] = gl[k] + gr[k]
+			}
+		case opSubtract:
+			v[i] = l - r
+			for k := range g {
+				g[k] = gl[k] - gr[k]
+			}
+		case opMultiply:
+			v[i] = l * r
+			for k := range g {
+				g[k] = gl[k]*r + l*gr[k]
+			}
+		case opSquare:
+			v[i] = l * l
+			scale(g, gl, 2*l)
+		case opNegate:
+			v[i] = l * -1.0
+			scale(g, gl, -1)
+		case opDivide:
+			v[i] = l / r
+			for k := range g {
+				g[k] = gl[k]/r - l*gr[k]/(r*r)
+			}
+		case opSqrt:
+			v[i] = math.Sqrt(l)
+			scale(g, gl, 1/(2*v[i]))
+		case opSin:
+			v[i] = math.Sin(l)
+			scale(g, gl, math.Cos(l))
+		case opCos:
+			v[i] = math.Cos(l)
+			scale(g, gl, -math.Sin(l))
+		case opAtan2:
+			// atan2(y, x)' = (x*y' - y*x') / (x^2 + y^2)
+			v[i] = math.Atan2(l, r)
+			for k := range g {
+				g[k] = (r*gl[k] - l*gr[k]) / (r*r + l*l)
+			}
+		case opAbs:
+			v[i] = math.Abs(l)
+			scale(g, gl, l/v[i])
+		case opPow:
+			v[i] = math.Pow(l, r)
+			if t.instructions[in.right].op == opConstant {
+				scale(g, gl, r*math.Pow(l, r-1))
+				continue
+			}
+			// (b^x)' = b^x * (x' * log(b) + x * b'/b)
+			for k := range g {
+				g[k] = v[i] * (gr[k]*math.Log(l) + r*gl[k]/l)
+			}
+		case opExp:
+			v[i] = math.Exp(l)
+			scale(g, gl, v[i])
+		case opLog:
+			v[i] = math.Log(l)
+			scale(g, gl, 1/l)
+		case opFunction:
+			// a black box only has its parameters as operands, its gradient
+			// is made of their partial derivatives
+			c := &t.calls[in.left]
+			for k, j := range c.args {
+				c.values[k] = 0
+				if j >= 0 {
+					c.values[k] = params.list[t.columns[j]].value
+				}
+			}
+
+			v[i] = c.apply()
+			clear(g)
+			for k, j := range c.args {
+				if j >= 0 {
+					g[j] += c.derivative(k)
+				}
+			}
+		}
+	}
+
+	t.valid = true
+	t.runs++
+}
+
+// scale sets g to factor * from
+func scale(g []float64, from []float64, factor float64) {
+	for k := range g {
+		g[k] = from[k] * factor
+	}
+}
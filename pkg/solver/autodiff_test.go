@@ -0,0 +1,82 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDual_Gradient(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("X", 3)
+	p.Add("Y", 4)
+
+	e := Param("X").Multiply(Param("Y")).Add(Param("X").Square())
+
+	dual := compileDual(e, []string{"X", "Y"}, []int{0, 1})
+
+	assert.Equal(t, []float64{10, 3}, dual.run(p))
+	assert.Equal(t, 21.0, dual.value())
+}
+
+func TestDual_OnePassPerPoint(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("Ax", 1)
+	p.Add("Ay", 2)
+	p.Add("Bx", 4)
+	p.Add("By", 6)
+
+	system := compileSystem([]*Expr{distance("A", "B")}, p, AUTODIFF)
+
+	assert.Equal(t, Vector{25}, system.residuals(p))
+	assert.Equal(t, Matrix{{-6, -8, 6, 8}}, system.jacobian(p))
+	assert.Equal(t, 1, system.duals[0].runs)
+	assert.Empty(t, system.graph.nodes)
+
+	p.saveVec(Vector{0, 0, 3, 4})
+
+	assert.Equal(t, Matrix{{-6, -8, 6, 8}}, system.jacobian(p))
+	assert.Equal(t, Vector{25}, system.residuals(p))
+	assert.Equal(t, 2, system.duals[0].runs)
+}
+
+func TestDual_UnknownParameter(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("X", 2)
+
+	dual := compileDual(Param("X").Multiply(Param("Z")), []string{"X"}, []int{0})
+
+	assert.Equal(t, []float64{0}, dual.run(p))
+}
+
+// TestDual_MatchesPartialDiff compares the forward mode gradient with the
+// symbolic derivatives on random trees with every node type
+func TestDual_MatchesPartialDiff(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+
+	types := builderTypes()
+
+	for i := 0; i < 1000; i++ {
+		e := nodeBuilders[types[i%len(types)]](randomExpr(r, types, 3), randomExpr(r, types, 3))
+
+		p := &SystemParameters{}
+		p.Add("X", r.Float64()*4-2)
+		p.Add("Y", r.Float64()*4-2)
+
+		gradient := compileDual(e, []string{"X", "Y"}, []int{0, 1}).run(p)
+
+		for k, by := range []string{"X", "Y"} {
+			expected := e.PartialDiff(by).Eval(p)
+			if math.IsNaN(expected) || math.IsInf(expected, 0) {
+				continue
+			}
+
+			if !assert.InDelta(t, expected, gradient[k], 1e-9*math.Max(1, math.Abs(expected)), e.Format()) {
+				return
+			}
+		}
+	}
+}
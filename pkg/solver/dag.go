@@ -0,0 +1,70 @@
+package solver
+
+// dagNode is an Expr node whose operands are indices of earlier nodes
+type dagNode struct {
+	Type  ExprType
+	left  int // -1 if there's no operand
+	right int
+	value float64
+	name  string
+	fn    *Function
+}
+
+// dag interns expressions into one graph in which structurally equal nodes
+// are stored once. The nodes are in topological order, so a single pass
+// evaluates every expression, each shared subexpression once.
+type dag struct {
+	nodes    []dagNode
+	index    map[dagNode]int
+	interned map[*Expr]int
+
+	tape *tape // compiled on the first eval after nodes were added
+}
+
+func newDAG() *dag {
+	return &dag{index: map[dagNode]int{}, interned: map[*Expr]int{}}
+}
+
+// intern adds the expression and returns the index of its root
+func (g *dag) intern(e *Expr) int {
+	if e == nil {
+		return -1
+	}
+
+	if i, ok := g.interned[e]; ok {
+		return i
+	}
+
+	node := dagNode{e.Type, g.intern(e.Left), g.intern(e.Right), e.Value, e.Name, e.Func}
+
+	i, ok := g.index[node]
+	if !ok {
+		i = len(g.nodes)
+		g.nodes = append(g.nodes, node)
+		g.index[node] = i
+	}
+
+	g.interned[e] = i
+
+	return i
+}
+
+func (g *dag) internAll(expressions []*Expr) []int {
+	result := make([]int, len(expressions))
+	for i, e := range expressions {
+		result[i] = g.intern(e)
+	}
+
+	return result
+}
+
+// eval returns the values of all nodes from the compiled tape. They're cached
+// until the parameter values change, so residuals and Jacobian at the same
+// point cost one run.
+func (g *dag) eval(params *SystemParameters) []float64 {
+	if !g.tape.compiledFor(g.nodes, params) {
+		g.tape = compileTape(g.nodes, params)
+	}
+
+	return g.tape.run()
+}
@@ -0,0 +1,115 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func distance(a string, b string) *Expr {
+	return Param(a + "x").Subtract(Param(b + "x")).Square().
+		Add(Param(a + "y").Subtract(Param(b + "y")).Square())
+}
+
+func TestDAG_SharesEqualNodes(t *testing.T) {
+	g := newDAG()
+
+	first := g.intern(distance("A", "B"))
+	second := g.intern(distance("A", "B"))
+
+	assert.Equal(t, first, second)
+	// four parameters, two differences, two squares and the sum
+	assert.Len(t, g.nodes, 9)
+
+	// the derivative reuses Ax-Bx
+	before := len(g.nodes)
+	g.intern(Number(2).Multiply(Param("Ax").Subtract(Param("Bx"))))
+
+	assert.Equal(t, before+2, len(g.nodes))
+}
+
+func TestDAG_Eval(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+
+	types := builderTypes()
+
+	expressions := []*Expr{}
+	for i := 0; i < 200; i++ {
+		expressions = append(expressions, randomExpr(r, types, 4))
+	}
+
+	p := &SystemParameters{}
+	p.Add("X", 0.3)
+	p.Add("Y", -1.1)
+
+	system := compileSystem(expressions, p, SYMBOLIC)
+	got := system.residuals(p)
+
+	for i, e := range expressions {
+		expected := e.Eval(p)
+		if math.IsNaN(expected) {
+			assert.True(t, math.IsNaN(got[i]))
+			continue
+		}
+		assert.Equal(t, expected, got[i], e.Format())
+	}
+}
+
+func TestDAG_CachesValues(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("Ax", 1)
+	p.Add("Ay", 2)
+	p.Add("Bx", 4)
+	p.Add("By", 6)
+
+	system := compileSystem([]*Expr{distance("A", "B")}, p, SYMBOLIC)
+
+	assert.Equal(t, Vector{25}, system.residuals(p))
+	assert.Equal(t, Matrix{{-6, -8, 6, 8}}, system.jacobian(p))
+	assert.Equal(t, 1, system.graph.tape.runs)
+
+	p.saveVec(Vector{0, 0, 3, 4})
+
+	assert.Equal(t, Vector{25}, system.residuals(p))
+	assert.Equal(t, Matrix{{-6, -8, 6, 8}}, system.jacobian(p))
+	assert.Equal(t, 2, system.graph.tape.runs)
+}
+
+func TestDAG_MatchesTreeJacobian(t *testing.T) {
+	equations, params := chain(10)
+
+	system := compileSystem(equations, params, SYMBOLIC)
+
+	assert.Equal(t, evalSystem(equations, params), system.residuals(params))
+	assert.Equal(t, evalJacobian(createJacobian(equations, params), params), system.jacobian(params))
+}
+
+func BenchmarkDAG_Eval(b *testing.B) {
+	// the parameters change every iteration, like in a Newton step
+	equations, params := chain(50)
+
+	b.Run("tree", func(b *testing.B) {
+		J := createJacobian(equations, params)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			params.list[0].value = float64(i)
+			evalSystem(equations, params)
+			evalJacobian(J, params)
+		}
+	})
+
+	b.Run("dag", func(b *testing.B) {
+		system := compileSystem(equations, params, SYMBOLIC)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			params.list[0].value = float64(i)
+			system.residuals(params)
+			system.jacobian(params)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package solver
+
+import "sync"
+
+// subsystem is a connected component of the graph that joins every equation
+// to the parameters it depends on. Components don't share parameters, so
+// they can be solved one by one.
+type subsystem struct {
+	equations []int // indices into the equation system
+	params    []int // indices into the parameter list
+}
+
+// decompose splits the system into its connected components, ordered by
+// their first equation. Parameters no equation depends on are left out,
+// nothing moves them.
+func decompose(equations []*Expr, params *SystemParameters) []subsystem {
+	index := map[string]int{}
+	for i, p := range params.list {
+		index[p.name] = i
+	}
+
+	// union-find over the parameters
+	parent := make([]int, len(params.list))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	// first parameter of every equation, -1 if it has none
+	anchors := make([]int, len(equations))
+
+	for i, e := range equations {
+		anchors[i] = -1
+
+		for _, name := range e.Params() {
+			j, ok := index[name]
+			if !ok {
+				continue
+			}
+
+			if anchors[i] < 0 {
+				anchors[i] = j
+			} else {
+				parent[find(j)] = find(anchors[i])
+			}
+		}
+	}
+
+	result := []subsystem{}
+	byRoot := map[int]int{}
+
+	for i := range equations {
+		if anchors[i] < 0 {
+			result = append(result, subsystem{equations: []int{i}, params: []int{}})
+			continue
+		}
+
+		root := find(anchors[i])
+		k, ok := byRoot[root]
+		if !ok {
+			k = len(result)
+			byRoot[root] = k
+			result = append(result, subsystem{equations: []int{}, params: []int{}})
+		}
+
+		result[k].equations = append(result[k].equations, i)
+	}
+
+	for j := range params.list {
+		if k, ok := byRoot[find(j)]; ok {
+			result[k].params = append(result[k].params, j)
+		}
+	}
+
+	return result
+}
+
+// equationsOf picks the equations of the subsystem
+func (s subsystem) equationsOf(equations []*Expr) []*Expr {
+	result := make([]*Expr, len(s.equations))
+
+	for i, j := range s.equations {
+		result[i] = equations[j]
+	}
+
+	return result
+}
+
+// paramsOf returns a view of the subsystem's parameters, solving it updates
+// the values in params
+func (s subsystem) paramsOf(params *SystemParameters) *SystemParameters {
+	result := &SystemParameters{}
+
+	for _, j := range s.params {
+		result.insert(params.list[j])
+	}
+
+	return result
+}
+
+// solveSubsystems solves the components of the system on a pool of workers.
+// Components share no parameters, so every worker writes its own values and
+// the outcome is the same as solving them one by one.
+func solveSubsystems(equationSystem []*Expr, params *SystemParameters, options SolverOptions) []ComponentReport {
+	subsystems := decompose(equationSystem, params)
+	result := make([]ComponentReport, len(subsystems))
+
+	solve := func(k int) {
+		s := subsystems[k]
+		sub := s.paramsOf(params)
+
+		result[k] = ComponentReport{
+			Equations:   s.equations,
+			Parameters:  make([]string, len(sub.list)),
+			SolveReport: solveSubsystem(s.equationsOf(equationSystem), sub, options),
+		}
+
+		for i, p := range sub.list {
+			result[k].Parameters[i] = p.name
+		}
+	}
+
+	if options.Workers <= 1 {
+		for k := range subsystems {
+			solve(k)
+		}
+		return result
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < options.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range jobs {
+				solve(k)
+			}
+		}()
+	}
+
+	for k := range subsystems {
+		jobs <- k
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	return result
+}
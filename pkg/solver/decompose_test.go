@@ -0,0 +1,153 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	. "equation-solver/pkg/utils"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecompose(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("a", 1)
+	p.Add("b", 1)
+	p.Add("c", 1)
+	p.Add("d", 1)
+	p.Add("e", 1)
+
+	sys := []*Expr{
+		Param("c").Square(),
+		Param("a").Add(Param("d")),
+		Number(2),
+		Param("d").Multiply(Param("c")),
+		Param("b").Subtract(Number(1)),
+	}
+
+	expected := []subsystem{
+		{equations: []int{0, 1, 3}, params: []int{0, 2, 3}},
+		{equations: []int{2}, params: []int{}},
+		{equations: []int{4}, params: []int{1}},
+	}
+
+	assert.Equal(t, expected, decompose(sys, p))
+}
+
+func TestDecompose_ParamsOf(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("a", 1)
+	p.Add("b", 2)
+
+	sub := subsystem{equations: []int{}, params: []int{1}}.paramsOf(p)
+	sub.saveVec(Vector{5})
+
+	assert.Equal(t, 1, len(sub.list))
+	assert.Equal(t, 5.0, p.Get("b"))
+	assert.Equal(t, 1.0, p.Get("a"))
+}
+
+func TestDecompose_SolveSystem(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 1)
+	p.Add("u", 3)
+	p.Add("y", 1)
+	p.Add("v", 3)
+
+	sys := []*Expr{
+		Param("x").Square().Add(Param("y")),
+		Param("u").Square().Subtract(Number(2)),
+		Param("y").Square().Add(Param("x")).Subtract(Number(1)),
+		Param("v").Subtract(Param("u")),
+	}
+
+	report := SolveSystem(sys, p, DefaultSolverOptions())
+
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.Equal(t, 2, len(report.Components))
+	assert.Equal(t, []int{0, 2}, report.Components[0].Equations)
+	assert.Equal(t, []string{"x", "y"}, report.Components[0].Parameters)
+	assert.Equal(t, []int{1, 3}, report.Components[1].Equations)
+	assert.Equal(t, []string{"u", "v"}, report.Components[1].Parameters)
+	assert.Equal(t, evalSystem(sys, p), report.Residuals)
+	assert.Equal(t, AlmostEqual(p.Get("x"), 0.7244919590005157, 1e-9), true)
+	assert.Equal(t, AlmostEqual(p.Get("v"), 1.4142135623730951, 1e-9), true)
+}
+
+// a component without a root doesn't keep the others from converging
+func TestDecompose_FailureIsolated(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 3)
+	p.Add("u", 3)
+
+	sys := []*Expr{
+		Param("x").Square().Add(Number(1)),
+		Param("u").Square().Subtract(Number(2)),
+	}
+
+	report := SolveSystem(sys, p, DefaultSolverOptions())
+
+	assert.Equal(t, DIDNT_CONVERGE, report.Result)
+	assert.Equal(t, DIDNT_CONVERGE, report.Components[0].Result)
+	assert.Equal(t, CONVERGED, report.Components[1].Result)
+	assert.Equal(t, AlmostEqual(p.Get("u"), 1.4142135623730951, 1e-9), true)
+}
+
+func TestDecompose_ConstantEquation(t *testing.T) {
+	p := &SystemParameters{}
+
+	report := SolveSystem([]*Expr{Number(0)}, p, DefaultSolverOptions())
+	assert.Equal(t, CONVERGED, report.Result)
+
+	report = SolveSystem([]*Expr{Number(1)}, p, DefaultSolverOptions())
+	assert.Equal(t, OVERDEFINED, report.Result)
+}
+
+// clusters builds n unrelated points, each at distance 7 from two fixed
+// points 10 apart
+func clusters(n int) ([]*Expr, *SystemParameters) {
+	sys := []*Expr{}
+	p := &SystemParameters{}
+
+	for k := 0; k < n; k++ {
+		x := fmt.Sprintf("x%d", k)
+		y := fmt.Sprintf("y%d", k)
+		left := float64(100 * k)
+
+		p.Add(x, left+float64(k%7))
+		p.Add(y, float64(1+k%5))
+
+		for _, center := range []float64{left, left + 10} {
+			e := Param(x).Subtract(Number(center)).Square().
+				Add(Param(y).Square()).
+				Subtract(Number(49))
+			sys = append(sys, e)
+		}
+	}
+
+	return sys, p
+}
+
+func TestDecompose_Parallel(t *testing.T) {
+	sys, sequential := clusters(50)
+	_, parallel := clusters(50)
+
+	expected := SolveSystem(sys, sequential, SolverOptions{Workers: 1})
+	got := SolveSystem(sys, parallel, SolverOptions{Workers: 8})
+
+	assert.Equal(t, CONVERGED, got.Result)
+	assert.Equal(t, 50, len(got.Components))
+	assert.Equal(t, expected, got)
+	assert.Equal(t, sequential.getVec(), parallel.getVec())
+}
+
+func BenchmarkDecompose_Workers(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sys, p := clusters(200)
+				SolveSystem(sys, p, SolverOptions{Workers: workers})
+			}
+		})
+	}
+}
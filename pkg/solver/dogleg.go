@@ -0,0 +1,133 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	"math"
+)
+
+// damping of the Gauss-Newton step where J is singular, relative to JᵀJ
+const singularDamping = 1e-10
+
+// dogleg is Powell's trust region method. Every iteration combines the
+// Gauss-Newton step and the steepest descent step into one step no longer
+// than the trust radius. The radius grows while the linear model predicts
+// the reduction of the residual well and shrinks when it doesn't.
+func dogleg(system *compiledSystem, params *SystemParameters, options SolverOptions, report *SolveReport) {
+	if system.rows == 0 || system.cols == 0 {
+		report.Result = CONVERGED
+		return
+	}
+
+	J_x := system.jacobian(params)
+	F_x := system.residuals(params)
+
+	radius := options.TrustRadius * math.Max(params.getVec().Norm(), 1)
+
+	for i := 0; i < options.MaxIterations; i++ {
+		report.TrustRadii = append(report.TrustRadii, radius)
+
+		d := doglegStep(J_x, F_x, radius)
+
+		report.Iterations = i + 1
+		report.StepNorm = d.Norm()
+
+		if !isFinite(d) {
+			report.Result = SINGULAR
+			return
+		}
+
+		x := params.getVec()
+		params.saveVec(x.Subtract(d))
+		F_next := system.residuals(params)
+
+		// reduction of the squared residual, actual and predicted by J
+		actual := F_x.Dot(F_x) - F_next.Dot(F_next)
+		model := F_x.Subtract(multiplyVec(J_x, d))
+		predicted := F_x.Dot(F_x) - model.Dot(model)
+
+		if predicted <= 0 {
+			// stationary point, no step can reduce the residual any more
+			if options.converged(d, F_next) {
+				report.Result = CONVERGED
+			} else {
+				params.saveVec(x)
+			}
+			return
+		}
+
+		rho := actual / predicted
+
+		if rho < 0.25 {
+			radius /= 4
+		} else if rho > 0.75 && report.StepNorm > 0.99*radius {
+			radius *= 2
+		}
+
+		if rho <= 0 {
+			params.saveVec(x)
+			continue
+		}
+
+		F_x = F_next
+		J_x = system.jacobian(params)
+
+		if options.converged(d, F_x) {
+			report.Result = CONVERGED
+			return
+		}
+	}
+}
+
+// doglegStep returns the Gauss-Newton step if it fits in the trust region.
+// Otherwise it follows the steepest descent step and turns towards the
+// Gauss-Newton step until it hits the boundary of the region.
+func doglegStep(J Matrix, F Vector, radius float64) Vector {
+	gn, err := newtonStep(J, F)
+	if err != nil {
+		// a slightly damped Gauss-Newton step still exists for a singular J,
+		// it points along the directions the residual hardly depends on
+		JtJ, JtF := normalEquations(J, F)
+		gn, err = solveDamped(JtJ, JtF, singularDamping*math.Max(maxDiagonal(JtJ), 1))
+	}
+
+	if err == nil && gn.Norm() <= radius {
+		return gn
+	}
+
+	g := multiplyVec(J.Transpose(), F)
+	gNorm := g.Norm()
+	if gNorm == 0 {
+		return make(Vector, len(g))
+	}
+
+	// minimum of the linear model along the gradient
+	Jg := multiplyVec(J, g)
+	sd := g.Multiply(gNorm * gNorm / Jg.Dot(Jg))
+
+	if sd.Norm() >= radius {
+		return g.Multiply(radius / gNorm)
+	}
+
+	if err != nil {
+		return sd
+	}
+
+	// solve |sd + beta*(gn - sd)| = radius for beta
+	diff := gn.Subtract(sd)
+	a := diff.Dot(diff)
+	b := 2 * sd.Dot(diff)
+	c := sd.Dot(sd) - radius*radius
+	beta := (-b + math.Sqrt(b*b-4*a*c)) / (2 * a)
+
+	return sd.Add(diff.Multiply(beta))
+}
+
+func multiplyVec(m Matrix, v Vector) Vector {
+	result := make(Vector, len(m))
+
+	for i, row := range m {
+		result[i] = row.Dot(v)
+	}
+
+	return result
+}
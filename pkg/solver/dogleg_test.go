@@ -0,0 +1,93 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	. "equation-solver/pkg/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDogleg_SolveSystem(t *testing.T) {
+	p := &SystemParameters{}
+
+	p.add(SParam{"x", 1})
+	p.add(SParam{"y", 1})
+
+	sys := []*Expr{
+		Param("x").Square().Add(Param("y")),
+		Param("y").Square().Add(Param("x")).Subtract(Number(1)),
+	}
+
+	report := SolveSystem(sys, p, SolverOptions{Method: DOGLEG})
+
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.Equal(t, report.Iterations, len(report.TrustRadii))
+	assert.Equal(t, AlmostEqual(p.Get("x"), 0.7244919590005157, 1e-6), true)
+	assert.Equal(t, AlmostEqual(p.Get("y"), -0.5248885986564048, 1e-6), true)
+}
+
+// starting far from the root the radius limits the first steps and grows
+// once the model becomes reliable
+func TestDogleg_TrustRadius(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 1000)
+
+	sys := []*Expr{
+		Param("x").Square().Subtract(Number(2)),
+	}
+
+	report := SolveSystem(sys, p, SolverOptions{Method: DOGLEG, TrustRadius: 1e-3})
+
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.Equal(t, 1.0, report.TrustRadii[0])
+	assert.Greater(t, report.TrustRadii[1], report.TrustRadii[0])
+	assert.Equal(t, AlmostEqual(p.Get("x"), 1.4142135623730951, 1e-6), true)
+}
+
+func TestDogleg_NoRoot(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 3)
+
+	sys := []*Expr{
+		Param("x").Square().Add(Number(1)),
+	}
+
+	report := SolveSystem(sys, p, SolverOptions{Method: DOGLEG})
+
+	assert.Equal(t, DIDNT_CONVERGE, report.Result)
+	assert.Equal(t, AlmostEqual(p.Get("x"), 0, 1e-3), true)
+}
+
+func TestDogleg_Step(t *testing.T) {
+	J := Matrix{
+		{2, 0},
+		{0, 1},
+	}
+	F := Vector{2, 2}
+
+	// the Gauss-Newton step {1, 2} fits
+	assert.Equal(t, Vector{1, 2}, doglegStep(J, F, 10))
+
+	// only part of the steepest descent step fits, along the gradient {4, 2}
+	got := doglegStep(J, F, 0.5)
+	assert.Equal(t, AlmostEqual(got.Norm(), 0.5, 1e-12), true)
+	assert.Equal(t, AlmostEqual(got[0], 2*got[1], 1e-12), true)
+
+	// between the two the step ends on the boundary
+	got = doglegStep(J, F, 2)
+	assert.Equal(t, AlmostEqual(got.Norm(), 2, 1e-12), true)
+}
+
+func TestDogleg_StepSingular(t *testing.T) {
+	J := Matrix{
+		{1, 0},
+		{0, 0},
+	}
+	F := Vector{1, 1}
+
+	got := doglegStep(J, F, 10)
+
+	assert.Equal(t, AlmostEqual(got[0], 1, 1e-9), true)
+	assert.Equal(t, 0.0, got[1])
+}
@@ -3,6 +3,8 @@ package solver
 import (
 	"fmt"
 	"math"
+	"slices"
+	"strings"
 )
 
 type ExprType string
@@ -15,6 +17,16 @@ const (
 	MULTIPLY  ExprType = "MULTIPLY"
 	SQUARE    ExprType = "SQUARE"
 	NEGATE    ExprType = "NEGATE"
+	DIVIDE    ExprType = "DIVIDE"
+	SQRT      ExprType = "SQRT"
+	SIN       ExprType = "SIN"
+	COS       ExprType = "COS"
+	ATAN2     ExprType = "ATAN2" // atan2(Left, Right), the angle of the point (Right, Left)
+	ABS       ExprType = "ABS"
+	POW       ExprType = "POW" // Left to the power of Right
+	EXP       ExprType = "EXP"
+	LOG       ExprType = "LOG"      // natural logarithm
+	FUNCTION  ExprType = "FUNCTION" // Func of its parameters, a black box
 )
 
 type Expr struct {
@@ -23,6 +35,7 @@ type Expr struct {
 	Right *Expr
 	Value float64
 	Name  string
+	Func  *Function // FUNCTION only
 }
 
 func (e *Expr) PartialDiff(by string) *Expr {
@@ -59,6 +72,53 @@ func (e *Expr) PartialDiff(by string) *Expr {
 		return dLeft.Multiply(right).Add(left.Multiply(dRight))
 	case SQUARE:
 		return Number(2).Multiply(e.Left).Multiply(e.Left.PartialDiff(by))
+	case NEGATE:
+		return e.Left.PartialDiff(by).Negate()
+	case DIVIDE:
+		left := e.Left
+		right := e.Right
+		dLeft := left.PartialDiff(by)
+		dRight := right.PartialDiff(by)
+
+		// (l/r)' = l'/r - l*r'/r^2
+		return dLeft.Divide(right).Subtract(left.Multiply(dRight).Divide(right.Square()))
+	case SQRT:
+		return e.Left.PartialDiff(by).Divide(Number(2).Multiply(e))
+	case SIN:
+		return e.Left.Cos().Multiply(e.Left.PartialDiff(by))
+	case COS:
+		return e.Left.Sin().Multiply(e.Left.PartialDiff(by)).Negate()
+	case ATAN2:
+		y := e.Left
+		x := e.Right
+
+		// (x*y' - y*x') / (x^2 + y^2)
+		return x.Multiply(y.PartialDiff(by)).Subtract(y.Multiply(x.PartialDiff(by))).
+			Divide(x.Square().Add(y.Square()))
+	case ABS:
+		// the sign of the argument, undefined at 0
+		return e.Left.Divide(e).Multiply(e.Left.PartialDiff(by))
+	case POW:
+		base := e.Left
+		exponent := e.Right
+
+		if exponent.Type == CONSTANT {
+			return exponent.Multiply(base.Pow(Number(exponent.Value - 1))).Multiply(base.PartialDiff(by))
+		}
+
+		// (b^x)' = b^x * (x' * log(b) + x * b'/b)
+		return e.Multiply(exponent.PartialDiff(by).Multiply(base.Log()).
+			Add(exponent.Multiply(base.PartialDiff(by)).Divide(base)))
+	case EXP:
+		return e.Multiply(e.Left.PartialDiff(by))
+	case LOG:
+		return e.Left.PartialDiff(by).Divide(e.Left)
+	case FUNCTION:
+		if !slices.Contains(e.Func.Params, by) {
+			return Number(0)
+		}
+
+		return &Expr{FUNCTION, nil, nil, 0, "", e.Func.partial(by)}
 	}
 
 	panic("Can't differentiate")
@@ -84,56 +144,186 @@ func (e *Expr) Format() string {
 		return e.Left.Format() + "^2"
 	case NEGATE:
 		return "-" + e.Left.Format()
+	case DIVIDE:
+		return e.Left.Format() + "/" + e.Right.Format()
+	case SQRT:
+		return "sqrt(" + e.Left.Format() + ")"
+	case SIN:
+		return "sin(" + e.Left.Format() + ")"
+	case COS:
+		return "cos(" + e.Left.Format() + ")"
+	case ATAN2:
+		return "atan2(" + e.Left.Format() + ", " + e.Right.Format() + ")"
+	case ABS:
+		return "|" + e.Left.Format() + "|"
+	case POW:
+		return e.Left.Format() + "^" + e.Right.Format()
+	case EXP:
+		return "exp(" + e.Left.Format() + ")"
+	case LOG:
+		return "log(" + e.Left.Format() + ")"
+	case FUNCTION:
+		return e.Func.Name + "(" + strings.Join(e.Func.Params, ", ") + ")"
 	}
 
 	panic("Can't format")
 }
 
-func (e *Expr) Eval() float64 {
+// Eval computes the value of the expression with the parameter values in params
+func (e *Expr) Eval(params *SystemParameters) float64 {
 	switch e.Type {
 	case CONSTANT:
 		return e.Value
 	case PARAMETER:
-		return parameters[e.Name]
+		return params.value(e.Name)
+	case FUNCTION:
+		return e.Func.eval(params)
+	}
+
+	left := e.Left.Eval(params)
+	right := 0.0
+	if e.Right != nil {
+		right = e.Right.Eval(params)
+	}
+
+	return apply(e.Type, left, right)
+}
+
+// apply evaluates an operation node from the values of its operands, right is
+// ignored by unary operations
+func apply(t ExprType, left float64, right float64) float64 {
+	switch t {
 	case ADD:
-		return e.Left.Eval() + e.Right.Eval()
+		return left + right
 	case SUBTRACT:
-		return e.Left.Eval() - e.Right.Eval()
+		return left - right
 	case MULTIPLY:
-		return e.Left.Eval() * e.Right.Eval()
+		return left * right
 	case SQUARE:
-		return e.Left.Eval() * e.Left.Eval()
+		return left * left
 	case NEGATE:
-		return e.Left.Eval() * -1.0
+		return left * -1.0
+	case DIVIDE:
+		return left / right
+	case SQRT:
+		return math.Sqrt(left)
+	case SIN:
+		return math.Sin(left)
+	case COS:
+		return math.Cos(left)
+	case ATAN2:
+		return math.Atan2(left, right)
+	case ABS:
+		return math.Abs(left)
+	case POW:
+		return math.Pow(left, right)
+	case EXP:
+		return math.Exp(left)
+	case LOG:
+		return math.Log(left)
 	}
 
 	panic("Can't eval")
 }
 
+// Params returns the names of the parameters the expression depends on, in
+// the order they first appear
+func (e *Expr) Params() []string {
+	result := []string{}
+	seen := map[string]bool{}
+
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+
+	var walk func(e *Expr)
+	walk = func(e *Expr) {
+		if e == nil {
+			return
+		}
+
+		switch e.Type {
+		case PARAMETER:
+			add(e.Name)
+		case FUNCTION:
+			for _, name := range e.Func.Params {
+				add(name)
+			}
+		}
+
+		walk(e.Left)
+		walk(e.Right)
+	}
+
+	walk(e)
+
+	return result
+}
+
 func (e *Expr) Add(right *Expr) *Expr {
-	return &Expr{ADD, e, right, 0, ""}
+	return &Expr{ADD, e, right, 0, "", nil}
 }
 
 func (e *Expr) Subtract(right *Expr) *Expr {
-	return &Expr{SUBTRACT, e, right, 0, ""}
+	return &Expr{SUBTRACT, e, right, 0, "", nil}
 }
 
 func (e *Expr) Multiply(right *Expr) *Expr {
-	return &Expr{MULTIPLY, e, right, 0, ""}
+	return &Expr{MULTIPLY, e, right, 0, "", nil}
 }
 
 func (e *Expr) Square() *Expr {
-	return &Expr{SQUARE, e, nil, 0, ""}
+	return &Expr{SQUARE, e, nil, 0, "", nil}
 }
 
 func (e *Expr) Negate() *Expr {
-	return &Expr{NEGATE, e, nil, 0, ""}
+	return &Expr{NEGATE, e, nil, 0, "", nil}
+}
+
+func (e *Expr) Divide(right *Expr) *Expr {
+	return &Expr{DIVIDE, e, right, 0, "", nil}
+}
+
+func (e *Expr) Sqrt() *Expr {
+	return &Expr{SQRT, e, nil, 0, "", nil}
+}
+
+func (e *Expr) Sin() *Expr {
+	return &Expr{SIN, e, nil, 0, "", nil}
+}
+
+func (e *Expr) Cos() *Expr {
+	return &Expr{COS, e, nil, 0, "", nil}
+}
+
+func (e *Expr) Abs() *Expr {
+	return &Expr{ABS, e, nil, 0, "", nil}
+}
+
+func (e *Expr) Pow(exponent *Expr) *Expr {
+	return &Expr{POW, e, exponent, 0, "", nil}
+}
+
+func (e *Expr) Exp() *Expr {
+	return &Expr{EXP, e, nil, 0, "", nil}
+}
+
+func (e *Expr) Log() *Expr {
+	return &Expr{LOG, e, nil, 0, "", nil}
+}
+
+// Atan2 is the angle of the point (x, y) from the x axis, in (-π, π]
+func Atan2(y *Expr, x *Expr) *Expr {
+	return &Expr{ATAN2, y, x, 0, "", nil}
 }
 
 func Number(value float64) *Expr {
-	return &Expr{CONSTANT, nil, nil, value, ""}
+	return &Expr{CONSTANT, nil, nil, value, "", nil}
 }
 
 func Param(name string) *Expr {
-	return &Expr{PARAMETER, nil, nil, 0, name}
+	return &Expr{PARAMETER, nil, nil, 0, name, nil}
 }
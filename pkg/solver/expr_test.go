@@ -1,7 +1,14 @@
 package solver
 
 import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"math/rand"
+	"sort"
 	"testing"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -58,50 +65,303 @@ func TestExpr_Format(t *testing.T) {
 }
 
 func TestExpr_Eval(t *testing.T) {
-	parameters = map[string]float64{"X": 5, "Y": 3}
-	defer func() { parameters = map[string]float64{} }()
+	p := &SystemParameters{}
+	p.Add("X", 5)
+	p.Add("Y", 3)
 
 	assert := assert.New(t)
 
 	// CONSTANT
-	assert.Equal(3.14, Number(3.14).Eval())
+	assert.Equal(3.14, Number(3.14).Eval(p))
 
 	// PARAMETER
-	assert.Equal(5.0, Param("X").Eval())
+	assert.Equal(5.0, Param("X").Eval(p))
 
 	// ADD
 	add := Number(1).Add(Number(2))
-	assert.Equal(3.0, add.Eval())
+	assert.Equal(3.0, add.Eval(p))
 
 	// SUBTRACT
 	sub := Number(5).Subtract(Number(3))
-	assert.Equal(2.0, sub.Eval())
+	assert.Equal(2.0, sub.Eval(p))
 
 	// MULTIPLY
 	mul := Number(2).Multiply(Param("Y"))
-	assert.Equal(6.0, mul.Eval())
+	assert.Equal(6.0, mul.Eval(p))
 
 	// SQUARE
 	sq := Param("Y").Square()
-	assert.Equal(9.0, sq.Eval())
+	assert.Equal(9.0, sq.Eval(p))
 
 	// NEGATE
 	neg := Number(7).Negate()
-	assert.Equal(-7.0, neg.Eval())
+	assert.Equal(-7.0, neg.Eval(p))
+
+	// DIVIDE
+	assert.Equal(2.5, Param("X").Divide(Number(2)).Eval(p))
+
+	// SQRT
+	assert.Equal(3.0, Number(9).Sqrt().Eval(p))
+
+	// SIN and COS
+	assert.Equal(1.0, Number(math.Pi/2).Sin().Eval(p))
+	assert.Equal(-1.0, Number(math.Pi).Cos().Eval(p))
+
+	// ATAN2
+	assert.Equal(3*math.Pi/4, Atan2(Number(1), Number(-1)).Eval(p))
+
+	// ABS
+	assert.Equal(3.0, Param("Y").Negate().Abs().Eval(p))
+
+	// POW
+	assert.Equal(125.0, Param("X").Pow(Param("Y")).Eval(p))
+
+	// EXP and LOG
+	assert.Equal(math.E, Number(1).Exp().Eval(p))
+	assert.Equal(1.0, Number(math.E).Log().Eval(p))
+}
+
+func TestExpr_FormatFunctions(t *testing.T) {
+	assert.Equal(t, "X/2", Param("X").Divide(Number(2)).Format())
+	assert.Equal(t, "sqrt(X^2+Y^2)", Param("X").Square().Add(Param("Y").Square()).Sqrt().Format())
+	assert.Equal(t, "sin(X)", Param("X").Sin().Format())
+	assert.Equal(t, "cos(X)", Param("X").Cos().Format())
+	assert.Equal(t, "atan2(Y, X)", Atan2(Param("Y"), Param("X")).Format())
+	assert.Equal(t, "|X|", Param("X").Abs().Format())
+	assert.Equal(t, "X^0.50", Param("X").Pow(Number(0.5)).Format())
+	assert.Equal(t, "exp(X)", Param("X").Exp().Format())
+	assert.Equal(t, "log(X)", Param("X").Log().Format())
+}
+
+func TestExpr_DerivFunctions(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("X", 0.7)
+	p.Add("Y", 1.3)
+
+	x, y := 0.7, 1.3
+	X, Y := Param("X"), Param("Y")
+
+	cases := []struct {
+		name string
+		e    *Expr
+		dX   float64
+		dY   float64
+	}{
+		{"divide", X.Divide(Y), 1 / y, -x / (y * y)},
+		{"sqrt", X.Multiply(Y).Sqrt(), y / (2 * math.Sqrt(x*y)), x / (2 * math.Sqrt(x*y))},
+		{"sin", X.Multiply(Y).Sin(), y * math.Cos(x*y), x * math.Cos(x*y)},
+		{"cos", X.Multiply(Y).Cos(), -y * math.Sin(x*y), -x * math.Sin(x*y)},
+		{"atan2", Atan2(Y, X), -y / (x*x + y*y), x / (x*x + y*y)},
+		{"abs", X.Subtract(Y).Abs(), -1, 1},
+		{"pow constant", X.Pow(Number(3)), 3 * x * x, 0},
+		{"pow", X.Pow(Y), y * math.Pow(x, y-1), math.Pow(x, y) * math.Log(x)},
+		{"exp", X.Multiply(Y).Exp(), y * math.Exp(x*y), x * math.Exp(x*y)},
+		{"log", X.Multiply(Y).Log(), 1 / x, 1 / y},
+	}
+
+	for _, c := range cases {
+		assert.InDelta(t, c.dX, c.e.PartialDiff("X").Eval(p), 1e-12, c.name)
+		assert.InDelta(t, c.dY, c.e.PartialDiff("Y").Eval(p), 1e-12, c.name)
+	}
 }
 
 func TestExpr_EvalSquare(t *testing.T) {
-	parameters = map[string]float64{"X": 5, "Y": 3}
-	defer func() { parameters = map[string]float64{} }()
+	p := &SystemParameters{}
+	p.Add("X", 5)
+	p.Add("Y", 3)
 
 	e := Param("X").Subtract(Param("Y")).Square()
-	assert.Equal(t, 4.0, e.Eval())
+	assert.Equal(t, 4.0, e.Eval(p))
 }
 
 func TestExpr_DerivSquare(t *testing.T) {
-	parameters = map[string]float64{"X": 5, "Y": 3}
-	defer func() { parameters = map[string]float64{} }()
+	p := &SystemParameters{}
+	p.Add("X", 5)
+	p.Add("Y", 3)
 
 	e := Param("X").Subtract(Param("Y")).Square()
-	assert.Equal(t, -4.0, e.PartialDiff("Y").Eval())
+	assert.Equal(t, -4.0, e.PartialDiff("Y").Eval(p))
+}
+
+func TestExpr_Params(t *testing.T) {
+	e := Param("X").Subtract(Param("Y")).Square().Add(Number(2).Multiply(Param("X")))
+
+	assert.Equal(t, []string{"X", "Y"}, e.Params())
+	assert.Equal(t, []string{}, Number(1).Negate().Params())
+}
+
+func TestExpr_DerivNegate(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("X", 3)
+
+	e := Param("X").Square().Negate()
+
+	assert.Equal(t, "-2*X*1", e.PartialDiff("X").Format())
+	assert.Equal(t, -6.0, e.PartialDiff("X").Eval(p))
+}
+
+// exprTypes parses expr.go for the declared ExprType constants, so a new node
+// type is picked up by the tests below without registering it anywhere
+func exprTypes(t *testing.T) []ExprType {
+	file, err := parser.ParseFile(token.NewFileSet(), "expr.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := []ExprType{}
+
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			if ident, ok := value.Type.(*ast.Ident); ok && ident.Name == "ExprType" {
+				for _, name := range value.Names {
+					result = append(result, ExprType(name.Name))
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// builderTypes returns the types of nodeBuilders in a fixed order, so random
+// trees built from them are the same on every run
+func builderTypes() []ExprType {
+	result := []ExprType{}
+	for typ := range nodeBuilders {
+		result = append(result, typ)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+
+	return result
+}
+
+// nodeBuilders builds a node of each type from two random subtrees. Arguments
+// are shifted into the domain where the node is smooth. A black box hides a
+// bounded function, the rounding error of its differences grows with its value.
+var nodeBuilders = map[ExprType]func(a *Expr, b *Expr) *Expr{
+	ADD:      func(a, b *Expr) *Expr { return a.Add(b) },
+	SUBTRACT: func(a, b *Expr) *Expr { return a.Subtract(b) },
+	MULTIPLY: func(a, b *Expr) *Expr { return a.Multiply(b) },
+	SQUARE:   func(a, b *Expr) *Expr { return a.Square() },
+	NEGATE:   func(a, b *Expr) *Expr { return a.Negate() },
+	DIVIDE:   func(a, b *Expr) *Expr { return a.Divide(positive(b)) },
+	SQRT:     func(a, b *Expr) *Expr { return positive(a).Sqrt() },
+	SIN:      func(a, b *Expr) *Expr { return a.Sin() },
+	COS:      func(a, b *Expr) *Expr { return a.Cos() },
+	ATAN2:    func(a, b *Expr) *Expr { return Atan2(a, b) },
+	ABS:      func(a, b *Expr) *Expr { return a.Abs() },
+	POW:      func(a, b *Expr) *Expr { return positive(a).Pow(b) },
+	EXP:      func(a, b *Expr) *Expr { return a.Exp() },
+	LOG:      func(a, b *Expr) *Expr { return positive(a).Log() },
+	FUNCTION: func(a, b *Expr) *Expr { return blackBox(a.Add(b).Sin()) },
+}
+
+func positive(e *Expr) *Expr {
+	return e.Square().Add(Number(0.5))
+}
+
+// blackBox hides e in a Function of X and Y
+func blackBox(e *Expr) *Expr {
+	return Func("f", []string{"X", "Y"}, func(values []float64) float64 {
+		p := &SystemParameters{}
+		p.Add("X", values[0])
+		p.Add("Y", values[1])
+
+		return e.Eval(p)
+	})
+}
+
+func randomExpr(r *rand.Rand, types []ExprType, depth int) *Expr {
+	if depth == 0 || r.Intn(4) == 0 {
+		switch r.Intn(3) {
+		case 0:
+			return Param("X")
+		case 1:
+			return Param("Y")
+		}
+		return Number(math.Round(r.Float64()*40-20) / 10)
+	}
+
+	build := nodeBuilders[types[r.Intn(len(types))]]
+
+	return build(randomExpr(r, types, depth-1), randomExpr(r, types, depth-1))
+}
+
+func TestExpr_DerivCoversEveryType(t *testing.T) {
+	for _, typ := range exprTypes(t) {
+		if typ == CONSTANT || typ == PARAMETER {
+			continue
+		}
+
+		assert.Contains(t, nodeBuilders, typ, "no random builder for %s", typ)
+	}
+}
+
+// TestExpr_DerivFiniteDifferences compares PartialDiff with central
+// differences on random trees, every node type is the root of some of them
+func TestExpr_DerivFiniteDifferences(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	types := builderTypes()
+
+	checked := map[ExprType]int{}
+
+	for i := 0; i < 2000; i++ {
+		root := types[i%len(types)]
+		e := nodeBuilders[root](randomExpr(r, types, 3), randomExpr(r, types, 3))
+
+		p := &SystemParameters{}
+		p.Add("X", r.Float64()*4-2)
+		p.Add("Y", r.Float64()*4-2)
+
+		for _, by := range []string{"X", "Y"} {
+			symbolic := e.PartialDiff(by).Eval(p)
+
+			numeric, ok := centralDifference(e, p, by)
+			if !ok || math.IsNaN(symbolic) || math.Abs(symbolic) > 1e6 {
+				// near a kink or overflowing, the difference isn't reliable
+				continue
+			}
+
+			if !assert.InDelta(t, numeric, symbolic, 1e-5*math.Max(1, math.Abs(numeric)), e.Format()) {
+				return
+			}
+			checked[root]++
+		}
+	}
+
+	for _, typ := range types {
+		assert.Greater(t, checked[typ], 50, "too few checks of %s", typ)
+	}
+}
+
+// centralDifference estimates de/dby and reports whether two step sizes agree
+func centralDifference(e *Expr, p *SystemParameters, by string) (float64, bool) {
+	estimate := func(h float64) float64 {
+		param := p.byName[by]
+		x := param.value
+		defer func() { param.value = x }()
+
+		param.value = x + h
+		plus := e.Eval(p)
+		param.value = x - h
+		minus := e.Eval(p)
+
+		return (plus - minus) / (2 * h)
+	}
+
+	coarse := estimate(1e-4)
+	fine := estimate(1e-5)
+
+	if math.IsNaN(fine) || math.IsInf(fine, 0) {
+		return 0, false
+	}
+
+	return fine, math.Abs(coarse-fine) <= 1e-6*math.Max(1, math.Abs(fine))
 }
@@ -0,0 +1,122 @@
+package solver
+
+import (
+	"math"
+)
+
+// Function is a black box residual computed by Go code, for constraints that
+// can't be written as an Expr, like clearance checks or lookup tables. Eval
+// gets the values of Params in order. Its derivatives are estimated by
+// central differences.
+type Function struct {
+	Name   string
+	Params []string
+	Eval   func(values []float64) float64
+
+	of *Function // set if this is the derivative of another function by parameter by
+	by string
+}
+
+// Func wraps f into an expression that can be mixed with any other
+func Func(name string, params []string, f func(values []float64) float64) *Expr {
+	return &Expr{FUNCTION, nil, nil, 0, "", &Function{Name: name, Params: params, Eval: f}}
+}
+
+func (f *Function) eval(params *SystemParameters) float64 {
+	values := make([]float64, len(f.Params))
+	for i, name := range f.Params {
+		values[i] = params.value(name)
+	}
+
+	return f.Eval(values)
+}
+
+// partial returns the derivative by a parameter as another black box, so it
+// compiles and evaluates like the function itself
+func (f *Function) partial(by string) *Function {
+	result := &Function{Name: "d" + f.Name + "/d" + by, Params: f.Params, of: f, by: by}
+	result.Eval = func(values []float64) float64 {
+		return result.evalPartial(values, make([]float64, len(values)))
+	}
+
+	return result
+}
+
+// evalPartial evaluates a derivative function, scratch is as long as values
+func (f *Function) evalPartial(values []float64, scratch []float64) float64 {
+	sum := 0.0
+	for k, name := range f.Params {
+		if name == f.by {
+			sum += f.of.derivative(values, k, scratch)
+		}
+	}
+
+	return sum
+}
+
+// derivative estimates the derivative by the k-th parameter with central
+// differences D(h) = (f(x+h) - f(x-h)) / 2h. Richardson extrapolation of D(h)
+// and D(h/2) cancels the h² error term, what's left shrinks with h⁴ while
+// rounding grows with 1/h, so the step starts at ε^(1/5)·max(|x|, 1). The
+// function is evaluated at a copy of values in scratch.
+//
+// That assumes f is smooth at the scale of h, which D(h/4) checks: the error
+// from h/2 to h/4 has to be a quarter of the one from h to h/2. A lookup
+// table isn't smooth. If it doesn't change over the step, the step doubles
+// until it does, up to 1% of the scale. If f is flat over all of that, the
+// derivative is 0, which is what a clearance check that holds with room to
+// spare should report, it doesn't constrain the parameter there. Otherwise
+// D(h) of a table is off by up to a step of the table over 2h, so the step
+// keeps growing by √2 until D changes by less than 5%, up to the scale.
+func (f *Function) derivative(values []float64, k int, scratch []float64) float64 {
+	x := scratch[:len(values)]
+	copy(x, values)
+	at := x[k]
+	scale := math.Max(math.Abs(at), 1)
+
+	difference := func(h float64) float64 {
+		x[k] = at + h
+		plus := f.Eval(x)
+		upper := x[k]
+		x[k] = at - h
+		minus := f.Eval(x)
+		lower := x[k]
+		x[k] = at
+
+		// the step x can represent, not exactly 2h
+		return (plus - minus) / (upper - lower)
+	}
+
+	h := math.Pow(epsilon, 0.2) * scale
+	coarse := difference(h)
+
+	if coarse == 0 {
+		for coarse == 0 && h < 0.01*scale {
+			h *= 2
+			coarse = difference(h)
+		}
+		if coarse == 0 {
+			return 0
+		}
+	} else {
+		fine := difference(h / 2)
+		finer := difference(h / 4)
+
+		smooth := math.Abs(fine-coarse) <= 0.1*math.Abs(coarse) &&
+			math.Abs(4*(fine-finer)-(coarse-fine)) <= 0.5*math.Abs(coarse-fine)+1e-6*math.Abs(coarse)
+		if smooth {
+			return fine + (fine-coarse)/3
+		}
+	}
+
+	for h*math.Sqrt2 <= scale {
+		h *= math.Sqrt2
+		wider := difference(h)
+		if math.Abs(wider-coarse) <= 0.05*math.Abs(coarse) {
+			return wider
+		}
+		coarse = wider
+	}
+
+	return coarse
+}
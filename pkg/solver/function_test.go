@@ -0,0 +1,155 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hypot() *Expr {
+	return Func("hypot", []string{"X", "Y"}, func(values []float64) float64 {
+		return math.Hypot(values[0], values[1])
+	})
+}
+
+func TestFunction_Eval(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("X", 3)
+	p.Add("Y", 4)
+
+	e := hypot()
+
+	assert.Equal(t, 5.0, e.Eval(p))
+	assert.Equal(t, "hypot(X, Y)", e.Format())
+	assert.Equal(t, []string{"X", "Y"}, e.Params())
+	assert.Equal(t, []string{"Z", "X", "Y"}, Param("Z").Add(e).Params())
+}
+
+func TestFunction_PartialDiff(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("X", 3)
+	p.Add("Y", 4)
+
+	e := hypot()
+
+	assert.InDelta(t, 0.6, e.PartialDiff("X").Eval(p), 1e-10)
+	assert.InDelta(t, 0.8, e.PartialDiff("Y").Eval(p), 1e-10)
+	assert.Equal(t, Number(0), e.PartialDiff("Z"))
+	assert.Equal(t, "dhypot/dX(X, Y)", e.PartialDiff("X").Format())
+}
+
+func TestFunction_RepeatedParameter(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("X", 2)
+
+	// X * X
+	e := Func("square", []string{"X", "X"}, func(values []float64) float64 {
+		return values[0] * values[1]
+	})
+
+	assert.Equal(t, 4.0, e.Eval(p))
+	assert.InDelta(t, 4.0, e.PartialDiff("X").Eval(p), 1e-9)
+}
+
+func TestFunction_LookupTable(t *testing.T) {
+	// samples of 2x, the nearest is taken, so the average slope is 2 but it's
+	// flat between the samples. The secant has to span enough of them to be
+	// within 10% of it, wherever X is between two samples.
+	for _, spacing := range []float64{0.001, 0.01, 0.1} {
+		e := Func("table", []string{"X"}, func(values []float64) float64 {
+			return 2 * math.Round(values[0]/spacing) * spacing
+		})
+		d := e.PartialDiff("X")
+
+		for i := 0; i < 20; i++ {
+			p := &SystemParameters{}
+			p.Add("X", 10+float64(i)/20*spacing)
+
+			assert.InDelta(t, 2, d.Eval(p), 0.2, "spacing %g, X = %g", spacing, p.Get("X"))
+		}
+	}
+}
+
+func TestFunction_Flat(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("X", 10)
+
+	// a clearance of at least 2 from 5, which holds with room to spare, so
+	// the function is flat well beyond 1% of X
+	e := Func("clearance", []string{"X"}, func(values []float64) float64 {
+		return math.Max(0, 2-math.Abs(values[0]-5))
+	})
+
+	assert.Equal(t, 0.0, e.PartialDiff("X").Eval(p))
+}
+
+func TestFunction_DoesntAllocate(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("X", 3)
+	p.Add("Y", 4)
+
+	for _, mode := range []JacobianMode{SYMBOLIC, AUTODIFF} {
+		system := compileSystem([]*Expr{hypot().Subtract(Number(5))}, p, mode)
+
+		allocations := testing.AllocsPerRun(100, func() {
+			p.list[0].value++
+			if mode == AUTODIFF {
+				system.duals[0].run(p)
+			} else {
+				system.graph.eval(p)
+			}
+		})
+
+		assert.Equal(t, 0.0, allocations, mode)
+	}
+}
+
+func TestFunction_Simplify(t *testing.T) {
+	f := hypot()
+	g := hypot()
+
+	assert.Equal(t, f, f.Simplify())
+	assert.Equal(t, "2*hypot(X, Y)", f.Add(f).Simplify().Format())
+	assert.Equal(t, "hypot(X, Y)+hypot(X, Y)", f.Add(g).Simplify().Format())
+}
+
+func TestFunction_UnknownParameter(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("X", 3)
+
+	e := Func("hypot", []string{"X", "Z"}, func(values []float64) float64 {
+		return math.Hypot(values[0], values[1])
+	})
+
+	for _, mode := range jacobianModes {
+		system := compileSystem([]*Expr{e}, p, mode)
+
+		assert.Equal(t, Vector{3}, system.residuals(p), mode)
+		assert.InDelta(t, 1.0, system.jacobian(p)[0][0], 1e-6, mode)
+	}
+}
+
+// TestFunction_SolveSystem mixes a black box with symbolic equations, the
+// point at distance 5 from the origin and 3 from the y axis
+func TestFunction_SolveSystem(t *testing.T) {
+	for _, method := range []SolverMethod{NEWTON, LEVENBERG_MARQUARDT, DOGLEG} {
+		for _, mode := range jacobianModes {
+			p := &SystemParameters{}
+			p.Add("X", 1)
+			p.Add("Y", 1)
+
+			equations := []*Expr{
+				hypot().Subtract(Number(5)),
+				Param("X").Subtract(Number(3)),
+			}
+
+			report := SolveSystem(equations, p, SolverOptions{Method: method, Jacobian: mode})
+
+			assert.Equal(t, CONVERGED, report.Result, "%s %s", method, mode)
+			assert.InDelta(t, 3.0, p.Get("X"), 1e-6, "%s %s", method, mode)
+			assert.InDelta(t, 4.0, p.Get("Y"), 1e-6, "%s %s", method, mode)
+		}
+	}
+}
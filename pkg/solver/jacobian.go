@@ -0,0 +1,168 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	"math"
+)
+
+// compiledSystem evaluates the residuals and the Jacobian of an equation
+// system. Only the derivatives by the parameters an equation depends on are
+// computed, the others are zero, so the same compilation serves dense and
+// sparse solvers.
+type compiledSystem struct {
+	graph     *dag
+	equations []int // nodes of graph, except for AUTODIFF
+	rows      int
+	cols      int
+	columns   [][]int // columns[i] are the parameters equation i depends on
+	mode      JacobianMode
+
+	derivatives [][]int     // SYMBOLIC, nodes of graph, derivatives[i][k] is by columns[i][k]
+	duals       []*dualTape // AUTODIFF, one per equation
+}
+
+func compileSystem(equationSystem []*Expr, params *SystemParameters, mode JacobianMode) *compiledSystem {
+	graph := newDAG()
+
+	s := &compiledSystem{
+		graph:   graph,
+		rows:    len(equationSystem),
+		cols:    len(params.list),
+		columns: make([][]int, len(equationSystem)),
+		mode:    mode,
+	}
+
+	// the dual tapes compute the residuals along with the gradients
+	if mode != AUTODIFF {
+		s.equations = graph.internAll(equationSystem)
+	}
+
+	index := map[string]int{}
+	for j, p := range params.list {
+		index[p.name] = j
+	}
+
+	for i, e := range equationSystem {
+		names := []string{}
+		for _, name := range e.Params() {
+			if j, ok := index[name]; ok {
+				s.columns[i] = append(s.columns[i], j)
+				names = append(names, name)
+			}
+		}
+
+		switch mode {
+		case SYMBOLIC:
+			row := []int{}
+			for _, name := range names {
+				row = append(row, graph.intern(e.PartialDiff(name).Simplify()))
+			}
+			s.derivatives = append(s.derivatives, row)
+		case AUTODIFF:
+			s.duals = append(s.duals, compileDual(e, names, s.columns[i]))
+		case FINITE_DIFFERENCE:
+		default:
+			panic("Unknown Jacobian mode")
+		}
+	}
+
+	return s
+}
+
+func (s *compiledSystem) residuals(params *SystemParameters) Vector {
+	result := make(Vector, s.rows)
+
+	if s.mode == AUTODIFF {
+		for i, dual := range s.duals {
+			dual.run(params)
+			result[i] = dual.value()
+		}
+		return result
+	}
+
+	values := s.graph.eval(params)
+	for i, root := range s.equations {
+		result[i] = values[root]
+	}
+
+	return result
+}
+
+func (s *compiledSystem) jacobian(params *SystemParameters) Matrix {
+	result := NewMatrix(s.rows, s.cols)
+
+	s.eachDerivative(params, func(i int, j int, value float64) {
+		result[i][j] = value
+	})
+
+	return result
+}
+
+func (s *compiledSystem) sparseJacobian(params *SystemParameters) SparseMatrix {
+	entries := []SparseEntry{}
+
+	s.eachDerivative(params, func(i int, j int, value float64) {
+		entries = append(entries, SparseEntry{Row: i, Col: j, Value: value})
+	})
+
+	return NewSparseMatrix(s.rows, s.cols, entries)
+}
+
+// eachDerivative calls f with the derivative of equation i by parameter j for
+// every parameter the equation depends on
+func (s *compiledSystem) eachDerivative(params *SystemParameters, f func(i int, j int, value float64)) {
+	switch s.mode {
+	case SYMBOLIC:
+		values := s.graph.eval(params)
+		for i, row := range s.derivatives {
+			for k, node := range row {
+				f(i, s.columns[i][k], values[node])
+			}
+		}
+	case AUTODIFF:
+		for i, dual := range s.duals {
+			gradient := dual.run(params)
+			for k, j := range s.columns[i] {
+				f(i, j, gradient[k])
+			}
+		}
+	case FINITE_DIFFERENCE:
+		s.finiteDifferences(params, f)
+	}
+}
+
+// finiteDifferences estimates the derivatives by each parameter with central
+// differences, (F(x+h) - F(x-h)) / 2h. The step balances the truncation error,
+// which grows with h², against the rounding error, which grows with 1/h.
+func (s *compiledSystem) finiteDifferences(params *SystemParameters, f func(i int, j int, value float64)) {
+	// the equations depending on each parameter
+	rows := make([][]int, s.cols)
+	for i, columns := range s.columns {
+		for _, j := range columns {
+			rows[j] = append(rows[j], i)
+		}
+	}
+
+	for j, p := range params.list {
+		x := p.value
+		h := math.Cbrt(epsilon) * math.Max(math.Abs(x), 1)
+
+		p.value = x + h
+		plus := s.residuals(params)
+		upper := p.value
+		p.value = x - h
+		minus := s.residuals(params)
+		lower := p.value
+		p.value = x
+
+		// the step x can represent, not exactly 2h
+		step := upper - lower
+
+		for _, i := range rows[j] {
+			f(i, j, (plus[i]-minus[i])/step)
+		}
+	}
+}
+
+// epsilon is the spacing of float64 values around 1
+const epsilon = 2.220446049250313e-16
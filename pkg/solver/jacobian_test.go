@@ -0,0 +1,87 @@
+package solver
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var jacobianModes = []JacobianMode{SYMBOLIC, AUTODIFF, FINITE_DIFFERENCE}
+
+func TestJacobian_Modes(t *testing.T) {
+	equations, params := chain(10)
+	for i, p := range params.list {
+		p.value += float64(i%3) * 0.1
+	}
+
+	expected := evalJacobian(createJacobian(equations, params), params)
+
+	for _, mode := range jacobianModes {
+		system := compileSystem(equations, params, mode)
+
+		assert.Equal(t, evalSystem(equations, params), system.residuals(params), mode)
+
+		got := system.jacobian(params)
+		for i := range expected {
+			for j := range expected[i] {
+				assert.InDelta(t, expected[i][j], got[i][j], 1e-6*math.Max(1, math.Abs(expected[i][j])), mode)
+			}
+		}
+
+		assert.Equal(t, got, system.sparseJacobian(params).Dense(), mode)
+	}
+}
+
+func TestJacobian_FiniteDifferenceRestoresParameters(t *testing.T) {
+	equations, params := chain(5)
+	before := params.getVec()
+
+	compileSystem(equations, params, FINITE_DIFFERENCE).jacobian(params)
+
+	assert.Equal(t, before, params.getVec())
+}
+
+func TestJacobian_UnknownMode(t *testing.T) {
+	equations, params := chain(2)
+
+	assert.Panics(t, func() { compileSystem(equations, params, "REVERSE") })
+}
+
+func TestJacobian_SolveSystem(t *testing.T) {
+	for _, method := range []SolverMethod{NEWTON, LEVENBERG_MARQUARDT, DOGLEG} {
+		for _, linear := range []LinearSolver{DENSE, SPARSE} {
+			for _, mode := range jacobianModes {
+				equations, params := chain(10)
+
+				report := SolveSystem(equations, params, SolverOptions{Method: method, LinearSolver: linear, Jacobian: mode})
+
+				assert.Equal(t, CONVERGED, report.Result, "%s %s %s", method, linear, mode)
+				assert.InDelta(t, 40.0, params.Get("y9"), 1e-6, "%s %s %s", method, linear, mode)
+			}
+		}
+	}
+}
+
+func BenchmarkJacobian_Modes(b *testing.B) {
+	equations, params := chain(50)
+
+	for _, mode := range jacobianModes {
+		b.Run(string(mode)+"/compile", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				compileSystem(equations, params, mode)
+			}
+		})
+
+		b.Run(string(mode)+"/eval", func(b *testing.B) {
+			system := compileSystem(equations, params, mode)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				params.list[0].value = float64(i)
+				system.residuals(params)
+				system.jacobian(params)
+			}
+		})
+	}
+}
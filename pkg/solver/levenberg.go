@@ -0,0 +1,100 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	"math"
+)
+
+// levenbergMarquardt minimizes the sum of the squared residuals. Every step
+// solves (JᵀJ + λI)d = JᵀF: a small λ gives the Gauss-Newton step, a large
+// one a short step along the gradient. λ shrinks after a step that reduces
+// the residual and grows after one that doesn't, which is then undone, or
+// that can't be computed because the damped matrix is still singular.
+func levenbergMarquardt(system *compiledSystem, params *SystemParameters, options SolverOptions, report *SolveReport) {
+	if system.rows == 0 || system.cols == 0 {
+		report.Result = CONVERGED
+		return
+	}
+
+	J_x := system.jacobian(params)
+	F_x := system.residuals(params)
+
+	lambda := -1.0
+
+	for i := 0; i < options.MaxIterations; i++ {
+		A, g := normalEquations(J_x, F_x)
+
+		if lambda < 0 {
+			lambda = options.Damping * math.Max(maxDiagonal(A), 1)
+		}
+
+		d, err := solveDamped(A, g, lambda)
+
+		report.Iterations = i + 1
+
+		if err != nil {
+			// too little damping for a singular JᵀJ
+			lambda *= 10
+			continue
+		}
+
+		report.StepNorm = d.Norm()
+
+		if !isFinite(d) {
+			report.Result = SINGULAR
+			return
+		}
+
+		x := params.getVec()
+		params.saveVec(x.Subtract(d))
+		F_next := system.residuals(params)
+
+		if F_next.Norm() > F_x.Norm() {
+			params.saveVec(x)
+			lambda *= 10
+			continue
+		}
+
+		lambda /= 10
+		F_x = F_next
+		J_x = system.jacobian(params)
+
+		if options.converged(d, F_x) {
+			report.Result = CONVERGED
+			return
+		}
+	}
+}
+
+// solveDamped solves (A + λI)d = b, A is overwritten
+func solveDamped(A Matrix, b Vector, lambda float64) (Vector, error) {
+	for k := range A {
+		A[k][k] += lambda
+	}
+
+	return SolveGauss(A, b)
+}
+
+// normalEquations returns JᵀJ and JᵀF
+func normalEquations(J Matrix, F Vector) (Matrix, Vector) {
+	transpose := J.Transpose()
+
+	JtJ := transpose.MultiplyRight(J)
+	JtF := transpose.MultiplyRight(NewMatrixFromColVec(F))
+
+	g := make(Vector, len(JtF))
+	for i, row := range JtF {
+		g[i] = row[0]
+	}
+
+	return JtJ, g
+}
+
+func maxDiagonal(m Matrix) float64 {
+	result := 0.0
+	for i := range m {
+		result = math.Max(result, m[i][i])
+	}
+
+	return result
+}
@@ -0,0 +1,74 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	. "equation-solver/pkg/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevenberg_SolveSystem(t *testing.T) {
+	p := &SystemParameters{}
+
+	p.add(SParam{"x", 1})
+	p.add(SParam{"y", 1})
+
+	sys := []*Expr{
+		Param("x").Square().Add(Param("y")),
+		Param("y").Square().Add(Param("x")).Subtract(Number(1)),
+	}
+
+	report := SolveSystem(sys, p, SolverOptions{Method: LEVENBERG_MARQUARDT})
+
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.Equal(t, AlmostEqual(p.Get("x"), 0.7244919590005157, 1e-6), true)
+	assert.Equal(t, AlmostEqual(p.Get("y"), -0.5248885986564048, 1e-6), true)
+}
+
+// x^2 + 1 has no root, the damped iteration settles in the minimum of the
+// squared residual instead of jumping around
+func TestLevenberg_NoRoot(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 3)
+
+	sys := []*Expr{
+		Param("x").Square().Add(Number(1)),
+	}
+
+	report := SolveSystem(sys, p, SolverOptions{Method: LEVENBERG_MARQUARDT})
+
+	assert.Equal(t, DIDNT_CONVERGE, report.Result)
+	assert.Equal(t, AlmostEqual(p.Get("x"), 0, 1e-3), true)
+	assert.Equal(t, AlmostEqual(report.ResidualNorm, 1, 1e-6), true)
+}
+
+func TestLevenberg_Overdefined(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 0)
+
+	sys := []*Expr{
+		Param("x").Subtract(Number(2)),
+		Number(2).Multiply(Param("x")).Subtract(Number(4)),
+	}
+
+	report := SolveSystem(sys, p, SolverOptions{Method: LEVENBERG_MARQUARDT})
+
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.Equal(t, AlmostEqual(p.Get("x"), 2, 1e-6), true)
+}
+
+func TestLevenberg_NormalEquations(t *testing.T) {
+	J := Matrix{
+		{1, 2},
+		{3, 4},
+		{5, 6},
+	}
+	F := Vector{1, 0, 1}
+
+	JtJ, JtF := normalEquations(J, F)
+
+	assert.Equal(t, Matrix{{35, 44}, {44, 56}}, JtJ)
+	assert.Equal(t, Vector{6, 8}, JtF)
+	assert.Equal(t, Matrix{{1, 2}, {3, 4}, {5, 6}}, J)
+}
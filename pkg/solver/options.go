@@ -0,0 +1,124 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+)
+
+// ConvergenceTest decides when SolveSystem stops iterating
+type ConvergenceTest string
+
+const (
+	STEP              ConvergenceTest = "STEP"              // every parameter moved less than StepTolerance
+	RESIDUAL          ConvergenceTest = "RESIDUAL"          // every equation is within ResidualTolerance of zero
+	STEP_AND_RESIDUAL ConvergenceTest = "STEP_AND_RESIDUAL" // both of the above
+)
+
+// SolverMethod is the nonlinear iteration used by SolveSystem
+type SolverMethod string
+
+const (
+	NEWTON              SolverMethod = "NEWTON"
+	LEVENBERG_MARQUARDT SolverMethod = "LEVENBERG_MARQUARDT"
+	DOGLEG              SolverMethod = "DOGLEG"
+)
+
+// LinearSolver is how Newton's method solves for its step
+type LinearSolver string
+
+const (
+	DENSE  LinearSolver = "DENSE"  // Gaussian elimination on the full Jacobian
+	SPARSE LinearSolver = "SPARSE" // sparse LU on the nonzero derivatives only
+)
+
+// JacobianMode is how the Jacobian of the equations is computed
+type JacobianMode string
+
+const (
+	SYMBOLIC          JacobianMode = "SYMBOLIC"          // PartialDiff trees, simplified and compiled
+	AUTODIFF          JacobianMode = "AUTODIFF"          // forward mode, values and derivatives in one pass
+	FINITE_DIFFERENCE JacobianMode = "FINITE_DIFFERENCE" // central differences of the residuals
+)
+
+// SolverOptions tunes SolveSystem. Zero fields fall back to the values of
+// DefaultSolverOptions, so SolverOptions{} is a valid value.
+type SolverOptions struct {
+	StepTolerance     float64
+	ResidualTolerance float64
+	MaxIterations     int
+	Convergence       ConvergenceTest
+	Method            SolverMethod
+	Damping           float64 // initial Levenberg-Marquardt damping, relative to the largest entry of JᵀJ
+	TrustRadius       float64 // initial dogleg trust radius, relative to the norm of the starting parameters
+	Workers           int     // independent subsystems solved at the same time
+	LinearSolver      LinearSolver
+	Jacobian          JacobianMode
+}
+
+func DefaultSolverOptions() SolverOptions {
+	return SolverOptions{
+		StepTolerance:     1e-6,
+		ResidualTolerance: 1e-6,
+		MaxIterations:     100,
+		Convergence:       STEP_AND_RESIDUAL,
+		Method:            NEWTON,
+		Damping:           1e-3,
+		TrustRadius:       1,
+		Workers:           1,
+		LinearSolver:      DENSE,
+		Jacobian:          SYMBOLIC,
+	}
+}
+
+func (o SolverOptions) withDefaults() SolverOptions {
+	defaults := DefaultSolverOptions()
+
+	if o.StepTolerance == 0 {
+		o.StepTolerance = defaults.StepTolerance
+	}
+	if o.ResidualTolerance == 0 {
+		o.ResidualTolerance = defaults.ResidualTolerance
+	}
+	if o.MaxIterations == 0 {
+		o.MaxIterations = defaults.MaxIterations
+	}
+	if o.Convergence == "" {
+		o.Convergence = defaults.Convergence
+	}
+	if o.Method == "" {
+		o.Method = defaults.Method
+	}
+	if o.Damping == 0 {
+		o.Damping = defaults.Damping
+	}
+	if o.TrustRadius == 0 {
+		o.TrustRadius = defaults.TrustRadius
+	}
+	if o.Workers == 0 {
+		o.Workers = defaults.Workers
+	}
+	if o.LinearSolver == "" {
+		o.LinearSolver = defaults.LinearSolver
+	}
+	if o.Jacobian == "" {
+		o.Jacobian = defaults.Jacobian
+	}
+
+	return o
+}
+
+// converged checks the last step and the residuals after taking it
+func (o SolverOptions) converged(step Vector, residuals Vector) bool {
+	stepOk := step.NormInf() <= o.StepTolerance
+	residualOk := residuals.NormInf() <= o.ResidualTolerance
+
+	switch o.Convergence {
+	case STEP:
+		return stepOk
+	case RESIDUAL:
+		return residualOk
+	case STEP_AND_RESIDUAL:
+		return stepOk && residualOk
+	}
+
+	panic("Unknown convergence test")
+}
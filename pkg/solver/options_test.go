@@ -0,0 +1,35 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions_WithDefaults(t *testing.T) {
+	got := SolverOptions{MaxIterations: 10}.withDefaults()
+
+	expected := DefaultSolverOptions()
+	expected.MaxIterations = 10
+
+	assert.Equal(t, expected, got)
+}
+
+func TestOptions_Converged(t *testing.T) {
+	small := Vector{1e-8, -1e-8}
+	large := Vector{1, -1}
+
+	step := SolverOptions{Convergence: STEP}.withDefaults()
+	assert.True(t, step.converged(small, large))
+	assert.False(t, step.converged(large, small))
+
+	residual := SolverOptions{Convergence: RESIDUAL}.withDefaults()
+	assert.True(t, residual.converged(large, small))
+	assert.False(t, residual.converged(small, large))
+
+	both := SolverOptions{Convergence: STEP_AND_RESIDUAL}.withDefaults()
+	assert.True(t, both.converged(small, small))
+	assert.False(t, both.converged(small, large))
+	assert.False(t, both.converged(large, small))
+}
@@ -0,0 +1,214 @@
+package solver
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Simplify returns an equivalent expression with constants folded, the 0 and
+// 1 identities applied, double negations removed and like terms of sums
+// collected, so x*1+0-2*x becomes -x. The expression itself isn't modified.
+//
+// Zero times or divided by something is zero and terms of a sum that cancel,
+// like x - x, are dropped, even where what's dropped would evaluate to Inf or
+// NaN. So the result may be finite where the expression isn't, like 0*log(p)
+// at p = 0, but where the expression is finite both agree.
+func (e *Expr) Simplify() *Expr {
+	switch e.Type {
+	case CONSTANT, PARAMETER, FUNCTION:
+		return e
+	}
+
+	left := e.Left.Simplify()
+	var right *Expr
+	if e.Right != nil {
+		right = e.Right.Simplify()
+	}
+
+	simplified := &Expr{e.Type, left, right, 0, "", nil}
+
+	if left.Type == CONSTANT && (right == nil || right.Type == CONSTANT) {
+		return Number(simplified.Eval(&SystemParameters{}))
+	}
+
+	switch e.Type {
+	case ADD, SUBTRACT, NEGATE:
+		return collect(simplified)
+	case MULTIPLY:
+		return simplifyProduct(left, right)
+	case SQUARE:
+		if left.Type == NEGATE {
+			return left.Left.Square()
+		}
+	case DIVIDE:
+		if right.Type == CONSTANT && right.Value == 1 {
+			return left
+		}
+		if left.Type == CONSTANT && left.Value == 0 {
+			return Number(0)
+		}
+	case POW:
+		if right.Type == CONSTANT {
+			switch right.Value {
+			case 0:
+				return Number(1)
+			case 1:
+				return left
+			case 2:
+				return left.Square()
+			}
+		}
+	}
+
+	return simplified
+}
+
+// simplifyProduct simplifies l*r, both already simplified and not both
+// constant. A constant factor is moved to the front and merged with the
+// constant of the other factor.
+func simplifyProduct(l *Expr, r *Expr) *Expr {
+	if r.Type == CONSTANT {
+		l, r = r, l
+	}
+
+	if l.Type != CONSTANT {
+		return l.Multiply(r)
+	}
+
+	c := l.Value
+	if r.Type == MULTIPLY && r.Left.Type == CONSTANT {
+		c *= r.Left.Value
+		r = r.Right
+	}
+
+	switch c {
+	case 0:
+		return Number(0)
+	case 1:
+		return r
+	case -1:
+		return collect(r.Negate())
+	}
+
+	return Number(c).Multiply(r)
+}
+
+// term is coefficient * e in a sum
+type term struct {
+	coefficient float64
+	e           *Expr
+}
+
+// collect rebuilds a sum from its terms with the coefficients of equal terms
+// added up, constants last unless the sum would start with a negation
+func collect(e *Expr) *Expr {
+	terms := []*term{}
+	byKey := map[string]*term{}
+	constant := 0.0
+
+	var walk func(e *Expr, factor float64)
+	walk = func(e *Expr, factor float64) {
+		switch {
+		case e.Type == CONSTANT:
+			constant += factor * e.Value
+		case e.Type == ADD:
+			walk(e.Left, factor)
+			walk(e.Right, factor)
+		case e.Type == SUBTRACT:
+			walk(e.Left, factor)
+			walk(e.Right, -factor)
+		case e.Type == NEGATE:
+			walk(e.Left, -factor)
+		case e.Type == MULTIPLY && e.Left.Type == CONSTANT:
+			walk(e.Right, factor*e.Left.Value)
+		case e.Type == MULTIPLY && e.Right.Type == CONSTANT:
+			walk(e.Left, factor*e.Right.Value)
+		default:
+			key := e.key()
+			if t, ok := byKey[key]; ok {
+				t.coefficient += factor
+				return
+			}
+
+			t := &term{factor, e}
+			byKey[key] = t
+			terms = append(terms, t)
+		}
+	}
+
+	walk(e, 1)
+
+	var result *Expr
+
+	// c - x rather than -x + c
+	for _, t := range terms {
+		if t.coefficient != 0 {
+			if t.coefficient < 0 && constant != 0 {
+				result = Number(constant)
+				constant = 0
+			}
+			break
+		}
+	}
+
+	for _, t := range terms {
+		switch {
+		case t.coefficient == 0:
+			continue
+		case result == nil && t.coefficient == 1:
+			result = t.e
+		case result == nil && t.coefficient == -1:
+			result = t.e.Negate()
+		case result == nil:
+			result = Number(t.coefficient).Multiply(t.e)
+		case t.coefficient == 1:
+			result = result.Add(t.e)
+		case t.coefficient == -1:
+			result = result.Subtract(t.e)
+		case t.coefficient < 0:
+			result = result.Subtract(Number(-t.coefficient).Multiply(t.e))
+		default:
+			result = result.Add(Number(t.coefficient).Multiply(t.e))
+		}
+	}
+
+	switch {
+	case result == nil:
+		return Number(constant)
+	case constant > 0:
+		return result.Add(Number(constant))
+	case constant < 0:
+		return result.Subtract(Number(-constant))
+	}
+
+	return result
+}
+
+// key identifies the structure of an expression, equal keys evaluate equally
+func (e *Expr) key() string {
+	switch e.Type {
+	case CONSTANT:
+		return "CONSTANT(" + strconv.FormatFloat(e.Value, 'g', -1, 64) + ")"
+	case PARAMETER:
+		return "PARAMETER(" + strconv.Quote(e.Name) + ")"
+	case FUNCTION:
+		// black boxes with the same name may compute different things
+		return fmt.Sprintf("FUNCTION(%q@%p)", e.Func.Name, e.Func)
+	}
+
+	result := string(e.Type) + "(" + e.Left.key()
+	if e.Right != nil {
+		result += "," + e.Right.key()
+	}
+
+	return result + ")"
+}
+
+// size counts the nodes of the expression
+func (e *Expr) size() int {
+	if e == nil {
+		return 0
+	}
+
+	return 1 + e.Left.size() + e.Right.size()
+}
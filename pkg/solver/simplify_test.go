@@ -0,0 +1,174 @@
+package solver
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimplify_Rules(t *testing.T) {
+	X, Y := Param("X"), Param("Y")
+
+	cases := []struct {
+		e        *Expr
+		expected string
+	}{
+		// constant folding
+		{Number(2).Multiply(Number(3)).Add(Number(1)), "7"},
+		{Number(3).Square(), "9"},
+		{Number(2).Square().Multiply(X), "4*X"},
+		// identities
+		{X.Add(Number(0)), "X"},
+		{Number(0).Add(X), "X"},
+		{X.Subtract(Number(0)), "X"},
+		{Number(0).Subtract(X), "-X"},
+		{X.Multiply(Number(1)), "X"},
+		{Number(1).Multiply(X), "X"},
+		{X.Multiply(Number(0)), "0"},
+		{Number(0).Multiply(X.Sin()), "0"},
+		{X.Divide(Number(1)), "X"},
+		{Number(0).Divide(X), "0"},
+		{X.Pow(Number(1)), "X"},
+		{X.Pow(Number(0)), "1"},
+		{X.Pow(Number(2)), "X^2"},
+		// negation
+		{X.Negate().Negate(), "X"},
+		{Number(-1).Multiply(X), "-X"},
+		{X.Negate().Square(), "X^2"},
+		{Number(3).Negate(), "-3"},
+		// constants of products merge
+		{Number(2).Multiply(Number(3).Multiply(X)), "6*X"},
+		{X.Multiply(Number(2)), "2*X"},
+		// like terms
+		{X.Add(X), "2*X"},
+		{X.Subtract(X), "0"},
+		{X.Multiply(Number(1)).Add(Number(0)).Subtract(Number(2).Multiply(X)), "-X"},
+		{X.Add(Y).Subtract(X).Add(Number(1)).Add(Number(2)), "Y+3"},
+		{X.Sin().Add(Y).Add(X.Sin()), "2*sin(X)+Y"},
+		{X.Subtract(Number(3).Multiply(Y)).Subtract(Number(2)), "X-3*Y-2"},
+		// derivatives
+		{Number(2).Multiply(X).Multiply(Number(0)), "0"},
+		{Number(0).Add(Number(0)), "0"},
+		{Number(0).Multiply(Number(5)), "0"},
+		{X.Square().Add(Y.Square()).PartialDiff("X"), "2*X"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, c.e.Simplify().Format(), c.e.Format())
+	}
+}
+
+func TestSimplify_KeepsExpression(t *testing.T) {
+	e := Param("X").Multiply(Number(1))
+
+	e.Simplify()
+
+	assert.Equal(t, "X*1", e.Format())
+}
+
+func TestSimplify_DropsNaN(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("X", 0)
+
+	X := Param("X")
+
+	assert.Equal(t, 0.0, Number(0).Multiply(X.Log()).Simplify().Eval(p))
+	assert.Equal(t, 0.0, Number(0).Divide(X).Simplify().Eval(p))
+	assert.Equal(t, 0.0, X.Log().Subtract(X.Log()).Simplify().Eval(p))
+}
+
+func TestSimplify_KeysDontCollide(t *testing.T) {
+	// a parameter named like a constant isn't a constant
+	assert.NotEqual(t, Number(1).key(), Param("1").key())
+	assert.NotEqual(t, Number(2).Multiply(Param("1")).key(), Number(2).Multiply(Number(1)).key())
+}
+
+// TestSimplify_Equivalent checks that simplified random trees evaluate to the
+// same values, or to a finite value where dropped terms made the tree Inf or
+// NaN
+func TestSimplify_Equivalent(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+
+	types := builderTypes()
+	finite := func(x float64) bool { return !math.IsNaN(x) && !math.IsInf(x, 0) }
+	nonFinite := 0
+
+	for i := 0; i < 1000; i++ {
+		e := randomExpr(r, types, 4)
+		simplified := e.Simplify()
+
+		p := &SystemParameters{}
+		p.Add("X", r.Float64()*4-2)
+		p.Add("Y", r.Float64()*4-2)
+
+		expected := e.Eval(p)
+		got := simplified.Eval(p)
+
+		assert.LessOrEqual(t, simplified.size(), e.size())
+
+		switch {
+		case finite(expected):
+			if !assert.InDelta(t, expected, got, 1e-9*math.Max(1, math.Abs(expected)), e.Format()) {
+				return
+			}
+			continue
+		case finite(got):
+			// what made it Inf or NaN was dropped
+		default:
+			// formatted, so NaN equals NaN
+			if !assert.Equal(t, fmt.Sprint(expected), fmt.Sprint(got), e.Format()) {
+				return
+			}
+		}
+		nonFinite++
+	}
+
+	assert.Greater(t, nonFinite, 0)
+}
+
+func TestSimplify_Jacobian(t *testing.T) {
+	equations, params := chain(20)
+
+	raw, simplified := 0, 0
+	for _, e := range equations {
+		for _, p := range params.list {
+			raw += e.PartialDiff(p.name).size()
+		}
+	}
+	for _, row := range createJacobian(equations, params) {
+		for _, d := range row {
+			simplified += d.size()
+		}
+	}
+
+	assert.Less(t, simplified, raw/2)
+}
+
+func BenchmarkSimplify_JacobianEval(b *testing.B) {
+	equations, params := chain(50)
+
+	rawJacobian := make([][]*Expr, len(equations))
+	for i, e := range equations {
+		for _, p := range params.list {
+			rawJacobian[i] = append(rawJacobian[i], e.PartialDiff(p.name))
+		}
+	}
+
+	b.Run("raw", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			evalJacobian(rawJacobian, params)
+		}
+	})
+
+	b.Run("simplified", func(b *testing.B) {
+		J := createJacobian(equations, params)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			evalJacobian(J, params)
+		}
+	})
+}
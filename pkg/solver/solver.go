@@ -9,66 +9,119 @@ import (
 type Result string
 
 const (
-	CONVERGED    Result = "CONVERGED"
-	UNDERDEFINED Result = "UNDERDEFINED"
-	OVERDEFINED  Result = "OVERDEFINED"
+	CONVERGED      Result = "CONVERGED"
+	DIDNT_CONVERGE Result = "DIDNT_CONVERGE"
+	SINGULAR       Result = "SINGULAR"
+	UNDERDEFINED   Result = "UNDERDEFINED"
+	OVERDEFINED    Result = "OVERDEFINED"
 )
 
+// SolveReport describes how SolveSystem ended
+type SolveReport struct {
+	Result       Result
+	Iterations   int               // the most iterations any component took
+	ResidualNorm float64           // euclidean norm of the residuals at the final parameters
+	StepNorm     float64           // euclidean norm of the last step
+	Residuals    Vector            // value of each equation at the final parameters
+	Err          error             // why the result is SINGULAR
+	TrustRadii   []float64         // trust radius of every dogleg iteration of the component with the most iterations
+	Components   []ComponentReport // outcome of each independent subsystem
+}
+
+// ComponentReport describes how an independent subsystem was solved, its
+// Residuals belong to Equations
+type ComponentReport struct {
+	Equations  []int // indices of the equations in the whole system
+	Parameters []string
+	SolveReport
+}
+
+// SystemParameters is the table of unknowns of an equation system. It owns
+// the parameter values, expressions are evaluated against it.
 type SystemParameters struct {
-	list []*SParam
+	list   []*SParam
+	byName map[string]*SParam
 }
 
 func (sp *SystemParameters) add(newParam SParam) {
-	sp.list = append(sp.list, &newParam)
-	parameters[newParam.name] = newParam.value
+	sp.insert(&newParam)
 }
 
 func (sp *SystemParameters) Add(name string, value float64) {
-	p := &SParam{name, value}
+	sp.insert(&SParam{name, value})
+}
+
+func (sp *SystemParameters) insert(p *SParam) {
+	if sp.byName == nil {
+		sp.byName = map[string]*SParam{}
+	}
+
 	sp.list = append(sp.list, p)
-	parameters[p.name] = p.value
+	sp.byName[p.name] = p
 }
 
 func (sp *SystemParameters) Get(name string) float64 {
-	for _, p := range sp.list {
-		if p.name == name {
-			return p.value
-		}
+	value, err := sp.Lookup(name)
+	if err != nil {
+		panic("No param like that")
 	}
 
-	panic("No param like that")
+	return value
 }
 
-func (sp *SystemParameters) getVec() Vector {
-	vector := make(Vector, len(sp.list))
+// Lookup is Get that returns an *UnknownParameterError instead of panicking
+func (sp *SystemParameters) Lookup(name string) (float64, error) {
+	if p, ok := sp.byName[name]; ok {
+		return p.value, nil
+	}
 
-	for i, p := range sp.list {
-		vector[i] = p.value
+	return 0, &UnknownParameterError{Name: name}
+}
+
+// UnknownParameterError is returned when looking up a parameter that was
+// never added
+type UnknownParameterError struct {
+	Name string
+}
+
+func (e *UnknownParameterError) Error() string {
+	return fmt.Sprintf("unknown parameter %q", e.Name)
+}
+
+// value looks up a parameter during evaluation, unknown names evaluate to 0
+func (sp *SystemParameters) value(name string) float64 {
+	if p, ok := sp.byName[name]; ok {
+		return p.value
 	}
 
-	return vector
+	return 0
 }
 
-func (sp *SystemParameters) save() {
-	paramList = paramList[:0]
+func (sp *SystemParameters) getVec() Vector {
+	vector := make(Vector, len(sp.list))
 
-	for _, p := range sp.list {
-		parameters[p.name] = p.value
-		paramList = append(paramList, p.name)
+	for i, p := range sp.list {
+		vector[i] = p.value
 	}
 
+	return vector
 }
 
 func (sp *SystemParameters) saveVec(vector Vector) {
 	for i, value := range vector {
-		current := sp.list[i]
-		current.value = value
-		parameters[current.name] = value
+		sp.list[i].value = value
 	}
 }
 
-func (sp *SystemParameters) clear() {
-	parameters = map[string]float64{}
+// clone copies the parameters, solving the copy leaves sp as it is
+func (sp *SystemParameters) clone() *SystemParameters {
+	result := &SystemParameters{}
+
+	for _, p := range sp.list {
+		result.Add(p.name, p.value)
+	}
+
+	return result
 }
 
 func (sp *SystemParameters) Format() string {
@@ -84,159 +137,273 @@ type SParam struct {
 	value float64
 }
 
-// Global variable containing the parameter values
-var parameters = make(map[string]float64)
-var paramList = make([]string, 0)
-
 type EquationSystem struct {
 	coefficients Matrix
 	constants    Vector
 }
 
-// Solve attempts to solve the given matrix equation using Gaussian elimination.
+// an over-determined system is consistent if its least squares residual is
+// this small, relative to the norm of the constants
+const inconsistencyTolerance = 1e-9
+
+// Solve attempts to solve the given matrix equation. Square systems are solved
+// with Gaussian elimination, over-determined ones in the least squares sense
+// with a QR factorization, which unlike the normal equations doesn't square
+// the condition number.
 //
 // Parameters:
-//   - matrix: The augmented matrix representing the system of equations.
+//   - system: The coefficients and the constants of the equations.
 //
 // Returns:
-//   - Result: The outcome of the solving process (e.g., CONVERGED, UNDERDEFINED, OVERDEFINED).
-//   - Vector: The solution vector if a solution is found, otherwise nil.
-func Solve(system EquationSystem) (Result, Vector) {
+//   - Result: The outcome of the solving process (e.g., CONVERGED, SINGULAR, UNDERDEFINED, OVERDEFINED).
+//   - Vector: The solution vector if a solution is found, otherwise nil. For an
+//     OVERDEFINED system it's the least squares compromise.
+//   - float64: The norm of the residual Ax - b, how inconsistent the equations are.
+//   - error: A *SingularMatrixError if the coefficients are singular.
+func Solve(system EquationSystem) (Result, Vector, float64, error) {
 	coefficients := system.coefficients
-	constants := NewMatrixFromColVec(system.constants)
+	constants := system.constants
 
 	rows, cols := coefficients.Size()
-	if rows > cols {
-		//return OVERDEFINED, nil
-		transpose := coefficients.Copy().Transpose()
-		coefficients = transpose.MultiplyRight(coefficients)
-		constants = transpose.MultiplyRight(constants)
-	}
 
 	if cols > rows {
-		return UNDERDEFINED, nil
+		return UNDERDEFINED, nil, 0, nil
 	}
 
-	matrix := coefficients
-	matrix.Augment(constants)
+	if rows == cols {
+		solution, err := SolveGauss(coefficients, constants)
+		if err != nil {
+			return SINGULAR, nil, 0, err
+		}
+
+		return CONVERGED, solution, 0, nil
+	}
 
-	rows = matrix.Rows()
+	qr := coefficients.QR()
 
-	gaussEliminate(matrix, rows)
+	solution, err := qr.Solve(constants)
+	if err != nil {
+		return SINGULAR, nil, 0, err
+	}
 
-	solution := make(Vector, rows)
-	backSubstitute(matrix, rows, solution)
+	residual := qr.ResidualNorm(constants)
+	if residual > inconsistencyTolerance*math.Max(constants.Norm(), 1) {
+		return OVERDEFINED, solution, residual, nil
+	}
 
-	fmt.Println("\nRow echelon form\n", matrix)
+	return CONVERGED, solution, residual, nil
+}
 
-	return CONVERGED, solution
+// SolveGauss solves a square system with an LU factorization, it returns a
+// *SingularMatrixError instead of a solution full of NaN and Inf if the
+// coefficients are singular. The coefficients are left as they are.
+func SolveGauss(coefficients Matrix, constants Vector) (Vector, error) {
+	return coefficients.LU().Solve(constants)
 }
 
-func SolveGauss(coefficients Matrix, constants Vector) Vector {
-	rows := coefficients.Rows()
+// SolveSystem finds the parameter values that make every equation zero with
+// the method selected in options. The parameters are updated in place, the
+// returned report tells whether the iteration converged.
+//
+// The system is split into subsystems that share no parameters, which are
+// solved separately, on options.Workers goroutines. The system converged if
+// every subsystem did, otherwise the result is the one of the first
+// subsystem that failed.
+func SolveSystem(equationSystem []*Expr, params *SystemParameters, options SolverOptions) SolveReport {
+	options = options.withDefaults()
+	report := SolveReport{Result: CONVERGED, Components: []ComponentReport{}}
+
+	steps := Vector{}
+
+	for _, component := range solveSubsystems(equationSystem, params, options) {
+		report.Components = append(report.Components, component)
+
+		if report.Result == CONVERGED && component.Result != CONVERGED {
+			report.Result = component.Result
+			report.Err = component.Err
+		}
 
-	matrix := coefficients
-	matrix.AugmentVec(constants)
+		if component.Iterations > report.Iterations {
+			report.Iterations = component.Iterations
+			report.TrustRadii = component.TrustRadii
+		}
 
-	gaussEliminate(matrix, rows)
+		steps = append(steps, component.StepNorm)
+	}
 
-	solution := make(Vector, rows)
-	backSubstitute(matrix, rows, solution)
+	// the subsystems evaluated their equations at the final values already
+	report.Residuals = make(Vector, len(equationSystem))
+	for _, component := range report.Components {
+		for i, j := range component.Equations {
+			report.Residuals[j] = component.Residuals[i]
+		}
+	}
 
-	fmt.Println("\nRow echelon form\n", matrix)
+	report.StepNorm = steps.Norm()
+	report.ResidualNorm = report.Residuals.Norm()
 
-	return solution
+	return report
 }
 
-func SolveSystem(equationSystem []*Expr, params *SystemParameters) {
-	params.save()
-	defer func() { params.clear() }()
+func solveSubsystem(equationSystem []*Expr, params *SystemParameters, options SolverOptions) SolveReport {
+	report := SolveReport{Result: DIDNT_CONVERGE}
+	system := compileSystem(equationSystem, params, options.Jacobian)
 
-	for i := 0; i < 100; i++ {
-		J := createJacobian(equationSystem)
-		J_x := evalJacobian(J)
-		F_x := evalSystem(equationSystem)
+	if len(params.list) == 0 {
+		// nothing can move, the equations hold or they don't
+		report.Result = CONVERGED
+		if system.residuals(params).NormInf() > options.ResidualTolerance {
+			report.Result = OVERDEFINED
+		}
+	} else {
+		switch options.Method {
+		case NEWTON:
+			newton(system, params, options, &report)
+		case LEVENBERG_MARQUARDT:
+			levenbergMarquardt(system, params, options, &report)
+		case DOGLEG:
+			dogleg(system, params, options, &report)
+		default:
+			panic("Unknown solver method")
+		}
+	}
 
-		d := SolveGauss(J_x, F_x)
+	report.Residuals = system.residuals(params)
+	report.ResidualNorm = report.Residuals.Norm()
 
-		converged := true
-		for _, v := range d {
-			if math.Abs(v) > 1e-6 {
-				converged = false
-			}
+	return report
+}
+
+func newton(system *compiledSystem, params *SystemParameters, options SolverOptions, report *SolveReport) {
+	if system.rows == 0 {
+		report.Result = CONVERGED
+		return
+	}
+
+	// more equations than parameters are solved in the least squares sense,
+	// they only all hold if they're consistent
+	overdetermined := system.rows > system.cols
+
+	step := func(F Vector) (Vector, error) {
+		if options.LinearSolver == SPARSE {
+			return sparseNewtonStep(system.sparseJacobian(params), F)
 		}
+		return newtonStep(system.jacobian(params), F)
+	}
+
+	F_x := system.residuals(params)
+
+	for i := 0; i < options.MaxIterations; i++ {
+		d, err := step(F_x)
+
+		report.Iterations = i + 1
+
+		if err != nil {
+			report.Result = SINGULAR
+			report.Err = err
+			return
+		}
+
+		report.StepNorm = d.Norm()
 
-		if converged {
-			fmt.Printf("Converged after %d iterations\n", i+1)
-			break
+		if !isFinite(d) {
+			report.Result = SINGULAR
+			return
 		}
 
 		x := params.getVec()
 		next := x.Subtract(d)
 		params.saveVec(next)
-	}
-}
 
-// performs gaussian elimination with partial pivot
-func gaussEliminate(A Matrix, n int) {
-	for i := 0; i < n; i++ {
-		pivotRow := i
+		F_x = system.residuals(params)
+
+		// a least squares fit stops moving where the equations still disagree
+		stationary := overdetermined && d.NormInf() <= options.StepTolerance
 
-		for j := i + 1; j < n; j++ {
-			if math.Abs(A[j][i]) > math.Abs(A[pivotRow][i]) {
-				pivotRow = j
+		if options.converged(d, F_x) || stationary {
+			report.Result = CONVERGED
+			if overdetermined && F_x.NormInf() > options.ResidualTolerance {
+				report.Result = OVERDEFINED
 			}
+			return
 		}
+	}
+}
 
-		if pivotRow != i {
-			A.SwapRows(pivotRow, i)
+// newtonStep solves J d = F. An underdetermined system has many solutions,
+// it takes the one with the smallest norm, d = J⁺F from the SVD, so
+// parameters the equations don't pin down move as little as possible. An
+// overdetermined system is solved in the least squares sense with QR, which
+// unlike JᵀJ doesn't square the condition number of J.
+//
+// If J is rank deficient, redundant equations or a square system decompose
+// split off with dependent rows, d = J⁺F is the least squares solution with
+// the smallest norm. A square J only takes it if J d = F still holds, if it
+// doesn't, the equations pull in a direction J can't move and that's the
+// error of the elimination.
+func newtonStep(J Matrix, F Vector) (Vector, error) {
+	rows, cols := J.Size()
+
+	switch {
+	case rows < cols:
+		return multiplyVec(J.PseudoInverse(rankTolerance), F), nil
+	case rows > cols:
+		d, err := J.QR().Solve(F)
+		if err != nil {
+			return multiplyVec(J.PseudoInverse(rankTolerance), F), nil
 		}
+		return d, nil
+	}
 
-		for j := i + 1; j < n; j++ {
-			factor := A[j][i] / A[i][i]
-			A[j] = A[j].Subtract(A[i].Multiply(factor))
-		}
+	d, err := SolveGauss(J, F)
+	if err == nil {
+		return d, nil
 	}
+
+	d = multiplyVec(J.PseudoInverse(rankTolerance), F)
+	if multiplyVec(J, d).Subtract(F).Norm() > inconsistencyTolerance*math.Max(F.Norm(), 1) {
+		return nil, err
+	}
+
+	return d, nil
 }
 
-func backSubstitute(A Matrix, n int, x Vector) {
-	for i := n - 1; i >= 0; i-- {
-		sum := 0.0
-		for j := i + 1; j < n; j++ {
-			sum += A[i][j] * x[j]
+func isFinite(v Vector) bool {
+	for _, x := range v {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return false
 		}
-		x[i] = (A[i][n] - sum) / A[i][i]
 	}
+
+	return true
 }
 
-func createJacobian(equations []*Expr) [][]*Expr {
+func createJacobian(equations []*Expr, params *SystemParameters) [][]*Expr {
 	rows := len(equations)
-	cols := len(parameters)
+	cols := len(params.list)
 
 	// Jacobian
 	J := make([][]*Expr, rows)
 
 	for i, e := range equations {
 		J[i] = make([]*Expr, cols)
-		j := 0
-		for _, p := range paramList {
-			J[i][j] = e.PartialDiff(p)
-			j++
+		for j, p := range params.list {
+			J[i][j] = e.PartialDiff(p.name).Simplify()
 		}
 	}
 
 	return J
 }
 
-func evalJacobian(jacobian [][]*Expr) Matrix {
+func evalJacobian(jacobian [][]*Expr, params *SystemParameters) Matrix {
 	rows := len(jacobian)
 	cols := len(jacobian[0])
 
 	m := NewMatrix(rows, cols)
 
-	for i := 0; i < cols; i++ {
-		for j := 0; j < rows; j++ {
-			m[i][j] = jacobian[i][j].Eval()
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			m[i][j] = jacobian[i][j].Eval(params)
 		}
 	}
 
@@ -244,11 +411,11 @@ func evalJacobian(jacobian [][]*Expr) Matrix {
 
 }
 
-func evalSystem(system []*Expr) Vector {
+func evalSystem(system []*Expr, params *SystemParameters) Vector {
 	result := make(Vector, len(system))
 
 	for i, e := range system {
-		result[i] = e.Eval()
+		result[i] = e.Eval(params)
 	}
 
 	return result
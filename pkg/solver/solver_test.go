@@ -4,6 +4,8 @@ import (
 	. "equation-solver/pkg/math"
 	. "equation-solver/pkg/utils"
 	"fmt"
+	"math"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,10 +20,12 @@ func TestSolver_Solve(t *testing.T) {
 	c := Vector{3, 15, 14}
 	expected := Vector{2.9999999999999996, 0.9999999999999996, 2}
 
-	state, result := Solve(EquationSystem{m, c})
+	state, result, residual, err := Solve(EquationSystem{m, c})
 
+	assert.NoError(t, err)
 	assert.Equal(t, CONVERGED, state)
 	assert.Equal(t, expected, result)
+	assert.Equal(t, 0.0, residual)
 }
 
 func TestSolver_Solve_Overconstrained(t *testing.T) {
@@ -33,10 +37,47 @@ func TestSolver_Solve_Overconstrained(t *testing.T) {
 	c := Vector{1, 3, 2}
 	expected := Vector{1, 0.5}
 
-	state, result := Solve(EquationSystem{m, c})
+	state, result, residual, err := Solve(EquationSystem{m, c})
 
+	// the equations can't all hold, the residuals of the best fit are 0.5, -1, 0.5
+	assert.NoError(t, err)
+	assert.Equal(t, OVERDEFINED, state)
+	assert.InDeltaSlice(t, expected, result, 1e-12)
+	assert.InDelta(t, math.Sqrt(1.5), residual, 1e-12)
+}
+
+func TestSolver_Solve_OverconstrainedConsistent(t *testing.T) {
+	m := Matrix{
+		{1, 1},
+		{1, 2},
+		{1, 3},
+	}
+	c := Vector{3, 5, 7}
+
+	state, result, residual, err := Solve(EquationSystem{m, c})
+
+	assert.NoError(t, err)
 	assert.Equal(t, CONVERGED, state)
-	assert.Equal(t, expected, result)
+	assert.InDeltaSlice(t, Vector{1, 2}, result, 1e-12)
+	assert.InDelta(t, 0, residual, 1e-12)
+}
+
+func TestSolver_Solve_OverconstrainedIllConditioned(t *testing.T) {
+	// the columns are nearly dependent, JᵀJ has condition number around 1e16
+	// and the normal equations lose every digit
+	eps := 1e-8
+	m := Matrix{
+		{1, 1},
+		{eps, 0},
+		{0, eps},
+	}
+	c := Vector{2, eps, eps}
+
+	state, result, _, err := Solve(EquationSystem{m, c})
+
+	assert.NoError(t, err)
+	assert.Equal(t, CONVERGED, state)
+	assert.InDeltaSlice(t, Vector{1, 1}, result, 1e-6)
 }
 
 func TestSolver_CreateJacobian(t *testing.T) {
@@ -45,16 +86,12 @@ func TestSolver_CreateJacobian(t *testing.T) {
 	p.add(SParam{"A", 1})
 	p.add(SParam{"B", 2})
 
-	p.save()
-
-	defer func() { parameters = map[string]float64{} }()
-
 	es := []*Expr{
 		Param("A"),
 		Param("B"),
 	}
 
-	J := createJacobian(es)
+	J := createJacobian(es, p)
 
 	expected := [][]*Expr{
 		{Number(1), Number(0)},
@@ -70,16 +107,12 @@ func TestSolver_CreateJacobian2(t *testing.T) {
 	p.add(SParam{"A", 1})
 	p.add(SParam{"B", 2})
 
-	p.save()
-
-	defer func() { parameters = map[string]float64{} }()
-
 	es := []*Expr{
 		Param("A").Square(),
 		Param("B").Square(),
 	}
 
-	J := createJacobian(es)
+	J := createJacobian(es, p)
 
 	print(J[0][0].Format())
 	print(" ")
@@ -88,14 +121,15 @@ func TestSolver_CreateJacobian2(t *testing.T) {
 	print(" ")
 	println(J[1][1].Format())
 
+	// 2*A*1 and 2*A*0 simplified
 	expected := [][]*Expr{
 		{
-			Number(2).Multiply(Param("A")).Multiply(Number(1)),
-			Number(2).Multiply(Param("A")).Multiply(Number(0)),
+			Number(2).Multiply(Param("A")),
+			Number(0),
 		},
 		{
-			Number(2).Multiply(Param("B")).Multiply(Number(0)),
-			Number(2).Multiply(Param("B")).Multiply(Number(1)),
+			Number(0),
+			Number(2).Multiply(Param("B")),
 		},
 	}
 
@@ -103,16 +137,17 @@ func TestSolver_CreateJacobian2(t *testing.T) {
 }
 
 func TestSolver_EvalJacobian(t *testing.T) {
-	parameters = map[string]float64{"A": 1, "B": 2}
-	defer func() { parameters = map[string]float64{} }()
+	p := &SystemParameters{}
+	p.Add("A", 1)
+	p.Add("B", 2)
 
 	es := []*Expr{
 		Param("A"),
 		Param("B"),
 	}
 
-	J := createJacobian(es)
-	result := evalJacobian(J)
+	J := createJacobian(es, p)
+	result := evalJacobian(J, p)
 
 	expected := Matrix{
 		{1, 0},
@@ -123,8 +158,9 @@ func TestSolver_EvalJacobian(t *testing.T) {
 }
 
 func TestSolver_EvalJacobian2(t *testing.T) {
-	parameters = map[string]float64{"A": 1, "B": 2}
-	defer func() { parameters = map[string]float64{} }()
+	p := &SystemParameters{}
+	p.Add("A", 1)
+	p.Add("B", 2)
 
 	es := []*Expr{
 		Param("A").Square(),
@@ -135,7 +171,7 @@ func TestSolver_EvalJacobian2(t *testing.T) {
 		println(v.Format())
 	}
 
-	J := createJacobian(es)
+	J := createJacobian(es, p)
 
 	for _, r := range J {
 		for _, e := range r {
@@ -143,11 +179,11 @@ func TestSolver_EvalJacobian2(t *testing.T) {
 		}
 	}
 
-	result := evalJacobian(J)
+	result := evalJacobian(J, p)
 
 	/*
-		2*A*1 2*A*0
-		2*B*0 2*B*1
+		2*A 0
+		0 2*B
 	*/
 	expected := Matrix{
 		{2, 0},
@@ -158,14 +194,15 @@ func TestSolver_EvalJacobian2(t *testing.T) {
 }
 
 func TestSolver_EvalSystem(t *testing.T) {
-	parameters = map[string]float64{"A": 1, "B": 2}
-	defer func() { parameters = map[string]float64{} }()
+	p := &SystemParameters{}
+	p.Add("A", 1)
+	p.Add("B", 2)
 
 	es := []*Expr{
 		Param("A").Square(),
 		Param("B").Square(),
 	}
-	got := evalSystem(es)
+	got := evalSystem(es, p)
 
 	expected := Vector{1, 4}
 
@@ -173,18 +210,31 @@ func TestSolver_EvalSystem(t *testing.T) {
 }
 
 func TestSolver_Params(t *testing.T) {
-	defer func() { parameters = map[string]float64{} }()
-
 	p := &SystemParameters{}
 
 	p.add(SParam{"A", 1})
 
-	assert.Equal(t, parameters["A"], 1.0)
+	assert.Equal(t, 1.0, p.Get("A"))
+	assert.Equal(t, 1.0, Param("A").Eval(p))
 }
 
-func TestSolver_ParamsGetVec(t *testing.T) {
-	defer func() { parameters = map[string]float64{} }()
+func TestSolver_ParamsLookup(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 3)
+
+	value, err := p.Lookup("x")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, value)
+
+	_, err = p.Lookup("y")
+
+	assert.Equal(t, &UnknownParameterError{Name: "y"}, err)
+	assert.Equal(t, `unknown parameter "y"`, err.Error())
+	assert.Panics(t, func() { p.Get("y") })
+}
 
+func TestSolver_ParamsGetVec(t *testing.T) {
 	p := &SystemParameters{}
 
 	p.add(SParam{"A", 1})
@@ -199,8 +249,6 @@ func TestSolver_ParamsGetVec(t *testing.T) {
 }
 
 func TestSolver_SaveVec(t *testing.T) {
-	defer func() { parameters = map[string]float64{} }()
-
 	p := &SystemParameters{}
 
 	p.add(SParam{"A", 1})
@@ -211,34 +259,33 @@ func TestSolver_SaveVec(t *testing.T) {
 
 	p.saveVec(vec)
 
-	assert.Equal(t, 4.0, parameters["A"])
-	assert.Equal(t, 5.0, parameters["B"])
-	assert.Equal(t, 6.0, parameters["C"])
+	assert.Equal(t, 4.0, Param("A").Eval(p))
+	assert.Equal(t, 5.0, Param("B").Eval(p))
+	assert.Equal(t, 6.0, Param("C").Eval(p))
 
 	assert.Equal(t, 4.0, p.list[0].value)
 	assert.Equal(t, 5.0, p.list[1].value)
 	assert.Equal(t, 6.0, p.list[2].value)
 }
 
-// this order is not guaranteed
+// the Jacobian columns follow the order the parameters were added in
 func TestSolver_ParamIteration(t *testing.T) {
-	parameters = map[string]float64{"A": 1, "B": 2, "C": 3}
-	defer func() { parameters = map[string]float64{} }()
+	p := &SystemParameters{}
+
+	p.Add("C", 3)
+	p.Add("A", 1)
+	p.Add("B", 2)
 
 	var result []string
 
-	for p := range parameters {
-		result = append(result, p)
+	for _, param := range p.list {
+		result = append(result, param.name)
 	}
 
-	assert.Equal(t, []string{"A", "B", "C"}, result)
+	assert.Equal(t, []string{"C", "A", "B"}, result)
 }
 
 func TestSolver_SolveSystem(t *testing.T) {
-	defer func() {
-		parameters = map[string]float64{}
-	}()
-
 	p := &SystemParameters{}
 
 	p.add(SParam{"x", 1})
@@ -249,10 +296,313 @@ func TestSolver_SolveSystem(t *testing.T) {
 		Param("y").Square().Add(Param("x")).Subtract(Number(1)),
 	}
 
-	SolveSystem(sys, p)
+	report := SolveSystem(sys, p, DefaultSolverOptions())
 
-	fmt.Printf("%#v\n", parameters)
+	fmt.Print(p.Format())
+
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.Equal(t, 2, len(report.Residuals))
+	assert.Less(t, report.ResidualNorm, 1e-9)
+	assert.Less(t, report.StepNorm, 1e-6)
 
 	assert.Equal(t, AlmostEqual(p.list[0].value, 0.7244919590005157, 1e-9), true)
 	assert.Equal(t, AlmostEqual(p.list[1].value, -0.5248885986564048, 1e-9), true)
 }
+
+func TestSolver_SolveSystemConcurrent(t *testing.T) {
+	sys := []*Expr{
+		Param("x").Square().Add(Param("y")),
+		Param("y").Square().Add(Param("x")).Subtract(Number(1)),
+	}
+
+	params := make([]*SystemParameters, 8)
+	var wg sync.WaitGroup
+
+	for i := range params {
+		p := &SystemParameters{}
+		p.Add("x", 1)
+		p.Add("y", 1)
+		params[i] = p
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			SolveSystem(sys, p, DefaultSolverOptions())
+		}()
+	}
+
+	wg.Wait()
+
+	for _, p := range params {
+		assert.Equal(t, AlmostEqual(p.Get("x"), 0.7244919590005157, 1e-9), true)
+		assert.Equal(t, AlmostEqual(p.Get("y"), -0.5248885986564048, 1e-9), true)
+	}
+}
+
+func TestSolver_SolveSystemDidntConverge(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 3)
+
+	// x^2 + 1 has no real root, Newton keeps jumping around
+	sys := []*Expr{
+		Param("x").Square().Add(Number(1)),
+	}
+
+	report := SolveSystem(sys, p, DefaultSolverOptions())
+
+	assert.Equal(t, DIDNT_CONVERGE, report.Result)
+	assert.Equal(t, 100, report.Iterations)
+	assert.GreaterOrEqual(t, report.ResidualNorm, 1.0)
+}
+
+func TestSolver_SolveSystemSingular(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 1)
+
+	// the first step lands on x = 0 where the derivative vanishes
+	sys := []*Expr{
+		Param("x").Square().Add(Number(1)),
+	}
+
+	report := SolveSystem(sys, p, DefaultSolverOptions())
+
+	assert.Equal(t, SINGULAR, report.Result)
+	assert.Equal(t, 2, report.Iterations)
+	assert.Equal(t, &SingularMatrixError{Column: 0, Pivot: 0}, report.Err)
+	assert.Equal(t, 0.0, p.Get("x"))
+}
+
+func TestSolver_SolveSingular(t *testing.T) {
+	m := Matrix{
+		{1, 2},
+		{2, 4},
+	}
+	c := Vector{1, 2}
+
+	state, result, _, err := Solve(EquationSystem{m, c})
+
+	var singular *SingularMatrixError
+	assert.ErrorAs(t, err, &singular)
+	assert.Equal(t, 1, singular.Column)
+	assert.Equal(t, SINGULAR, state)
+	assert.Nil(t, result)
+}
+
+func TestSolver_SolveGaussSingular(t *testing.T) {
+	m := Matrix{
+		{0, 1, 0},
+		{0, 2, 1},
+		{0, 0, 3},
+	}
+	c := Vector{1, 2, 3}
+
+	result, err := SolveGauss(m, c)
+
+	assert.Equal(t, &SingularMatrixError{Column: 0, Pivot: 0}, err)
+	assert.Nil(t, result)
+}
+
+func TestSolver_SolveGaussKeepsInputs(t *testing.T) {
+	m := Matrix{
+		{2, 1},
+		{1, 3},
+	}
+	c := Vector{3, 5}
+
+	_, err := SolveGauss(m, c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Matrix{{2, 1}, {1, 3}}, m)
+	assert.Equal(t, Vector{3, 5}, c)
+}
+
+func TestSolver_SolveSystemUnderdefined(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 1)
+	p.Add("y", 1)
+
+	sys := []*Expr{
+		Param("x").Add(Param("y")),
+	}
+
+	report := SolveSystem(sys, p, DefaultSolverOptions())
+
+	// the closest point of the line x + y = 0
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.Equal(t, Vector{0}, report.Residuals)
+	assert.Equal(t, 0.0, p.Get("x"))
+	assert.Equal(t, 0.0, p.Get("y"))
+}
+
+func TestSolver_NewtonStepMinimumNorm(t *testing.T) {
+	J := Matrix{
+		{1, 0, 0},
+		{0, 1, 1},
+	}
+	F := Vector{1, 2}
+
+	got, err := newtonStep(J, F)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Vector{1, 1, 1}, got)
+	assert.Equal(t, Matrix{{1, 0, 0}, {0, 1, 1}}, J)
+}
+
+func TestSolver_NewtonStepRedundant(t *testing.T) {
+	// the same equation twice, J is rank deficient
+	J := Matrix{
+		{1, 0, 1},
+		{1, 0, 1},
+	}
+	F := Vector{2, 2}
+
+	got, err := newtonStep(J, F)
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{1, 0, 1}, got, 1e-12)
+}
+
+func TestSolver_NewtonStepLeastSquares(t *testing.T) {
+	J := Matrix{
+		{1},
+		{1},
+	}
+	F := Vector{1, 3}
+
+	got, err := newtonStep(J, F)
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{2}, got, 1e-15)
+}
+
+func TestSolver_NewtonStepLeastSquaresRankDeficient(t *testing.T) {
+	J := Matrix{
+		{1, 1},
+		{2, 2},
+		{3, 3},
+	}
+
+	got, err := newtonStep(J, Vector{1, 2, 3})
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{0.5, 0.5}, got, 1e-12)
+}
+
+func TestSolver_NewtonStepSquareRedundant(t *testing.T) {
+	// x + y + z = 3 twice, square but of rank 2
+	J := Matrix{
+		{1, 1, 1},
+		{1, 1, 1},
+		{1, -1, 0},
+	}
+
+	got, err := newtonStep(J, Vector{3, 3, 0})
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{1, 1, 1}, got, 1e-12)
+}
+
+func TestSolver_NewtonStepSquareConflicting(t *testing.T) {
+	J := Matrix{
+		{1, 1},
+		{1, 1},
+	}
+
+	_, err := newtonStep(J, Vector{1, 3})
+
+	var singular *SingularMatrixError
+	assert.ErrorAs(t, err, &singular)
+}
+
+func TestSolver_SolveSystemSquareRedundant(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 0)
+	p.Add("y", 0)
+	p.Add("z", 0)
+
+	sys := []*Expr{
+		Param("x").Add(Param("y")).Add(Param("z")).Subtract(Number(3)),
+		Param("x").Add(Param("y")).Add(Param("z")).Subtract(Number(3)),
+		Param("x").Square().Subtract(Param("y")),
+	}
+
+	report := SolveSystem(sys, p, SolverOptions{Method: NEWTON})
+
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.InDelta(t, 0.0, evalSystem(sys, p).Norm(), 1e-9)
+}
+
+func TestSolver_SolveSystemStepOnly(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 10)
+
+	sys := []*Expr{
+		Param("x").Square().Subtract(Number(2)),
+	}
+
+	// a coarse step tolerance stops while the residual is still large
+	report := SolveSystem(sys, p, SolverOptions{StepTolerance: 10, Convergence: STEP})
+
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.Equal(t, 1, report.Iterations)
+	assert.Greater(t, report.ResidualNorm, 1.0)
+
+	// the residual test keeps going until the equation holds
+	report = SolveSystem(sys, p, SolverOptions{StepTolerance: 10, Convergence: STEP_AND_RESIDUAL})
+
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.Less(t, report.ResidualNorm, 1e-6)
+	assert.Equal(t, AlmostEqual(p.Get("x"), math.Sqrt2, 1e-6), true)
+}
+
+func TestSolver_SolveSystemMaxIterations(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 10)
+
+	sys := []*Expr{
+		Param("x").Square().Subtract(Number(2)),
+	}
+
+	report := SolveSystem(sys, p, SolverOptions{MaxIterations: 2})
+
+	assert.Equal(t, DIDNT_CONVERGE, report.Result)
+	assert.Equal(t, 2, report.Iterations)
+}
+
+func TestSolver_SolveSystemOverdeterminedConsistent(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 1)
+	p.Add("y", 1)
+
+	// three equations through the point (4, 3)
+	sys := []*Expr{
+		Param("x").Square().Add(Param("y").Square()).Subtract(Number(25)),
+		Param("x").Add(Param("y")).Subtract(Number(7)),
+		Param("x").Subtract(Param("y")).Subtract(Number(1)),
+	}
+
+	report := SolveSystem(sys, p, SolverOptions{Method: NEWTON})
+
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.Greater(t, report.Iterations, 0)
+	assert.InDelta(t, 4.0, p.Get("x"), 1e-9)
+	assert.InDelta(t, 3.0, p.Get("y"), 1e-9)
+}
+
+func TestSolver_SolveSystemOverdeterminedConflicting(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 1)
+
+	sys := []*Expr{
+		Param("x").Square().Subtract(Number(1)),
+		Param("x").Square().Subtract(Number(9)),
+	}
+
+	report := SolveSystem(sys, p, SolverOptions{Method: NEWTON, Convergence: STEP})
+
+	// the least squares fit x² = 5, where both residuals are 4 off
+	assert.Equal(t, OVERDEFINED, report.Result)
+	assert.Greater(t, report.Iterations, 0)
+	assert.InDelta(t, math.Sqrt(5), p.Get("x"), 1e-6)
+	assert.InDelta(t, 4.0, report.Residuals.NormInf(), 1e-6)
+}
@@ -0,0 +1,32 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+)
+
+// sparseNewtonStep is newtonStep for a sparse J, it solves with sparse LU.
+// If the LU is singular, J is rank deficient and the step is left to the dense
+// newtonStep, as is a tall J that needs QR for its least squares step.
+func sparseNewtonStep(J SparseMatrix, F Vector) (Vector, error) {
+	rows, cols := J.Size()
+
+	if rows > cols {
+		return newtonStep(J.Dense(), F)
+	}
+
+	if rows < cols {
+		transpose := J.Transpose()
+		lu, err := J.MultiplyRight(transpose).LU()
+		if err != nil {
+			return newtonStep(J.Dense(), F)
+		}
+		return transpose.MultiplyVec(lu.Solve(F)), nil
+	}
+
+	lu, err := J.LU()
+	if err != nil {
+		return newtonStep(J.Dense(), F)
+	}
+
+	return lu.Solve(F), nil
+}
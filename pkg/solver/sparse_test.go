@@ -0,0 +1,138 @@
+package solver
+
+import (
+	. "equation-solver/pkg/math"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chain builds n points linked one after the other by distance 5, starting
+// at the origin. The x of every point is fixed, so the y follow the chain
+// and the whole system is one component.
+func chain(n int) ([]*Expr, *SystemParameters) {
+	sys := []*Expr{}
+	p := &SystemParameters{}
+
+	prevX, prevY := Number(0), Number(0)
+
+	for k := 0; k < n; k++ {
+		x := fmt.Sprintf("x%d", k)
+		y := fmt.Sprintf("y%d", k)
+
+		p.Add(x, float64(3*(k+1))+0.5)
+		p.Add(y, float64(4*(k+1))+0.3)
+
+		sys = append(sys,
+			Param(x).Subtract(prevX).Square().
+				Add(Param(y).Subtract(prevY).Square()).
+				Subtract(Number(25)),
+			Param(x).Subtract(Number(float64(3*(k+1)))),
+		)
+
+		prevX, prevY = Param(x), Param(y)
+	}
+
+	return sys, p
+}
+
+func TestSparse_Jacobian(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 1)
+	p.Add("y", 2)
+	p.Add("z", 3)
+
+	sys := []*Expr{
+		Param("x").Square().Add(Param("z")),
+		Param("y").Subtract(Number(1)),
+	}
+
+	system := compileSystem(sys, p, SYMBOLIC)
+
+	assert.Equal(t, [][]int{{0, 2}, {1}}, system.columns)
+	assert.Equal(t, evalJacobian(createJacobian(sys, p), p), system.sparseJacobian(p).Dense())
+}
+
+func TestSparse_SolveSystem(t *testing.T) {
+	sys, dense := chain(30)
+	_, sparse := chain(30)
+
+	expected := SolveSystem(sys, dense, DefaultSolverOptions())
+	got := SolveSystem(sys, sparse, SolverOptions{LinearSolver: SPARSE})
+
+	assert.Equal(t, CONVERGED, expected.Result)
+	assert.Equal(t, CONVERGED, got.Result)
+	assert.Equal(t, 1, len(got.Components))
+
+	x, y := dense.getVec(), sparse.getVec()
+	for i := range x {
+		assert.InDelta(t, x[i], y[i], 1e-9)
+	}
+	assert.InDelta(t, 120.0, sparse.Get("y29"), 1e-9)
+}
+
+func TestSparse_NewtonStep(t *testing.T) {
+	J := Matrix{
+		{1, 0, 0},
+		{0, 1, 1},
+	}
+	F := Vector{1, 2}
+
+	// minimum norm
+	got, err := sparseNewtonStep(NewSparseMatrixFromDense(J), F)
+	assert.NoError(t, err)
+	assert.Equal(t, Vector{1, 1, 1}, got)
+
+	// least squares
+	got, err = sparseNewtonStep(NewSparseMatrixFromDense(Matrix{{1}, {1}}), Vector{1, 3})
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{2}, got, 1e-12)
+
+	// singular
+	_, err = sparseNewtonStep(NewSparseMatrixFromDense(Matrix{{1, 1}, {1, 1}}), Vector{1, 3})
+	assert.Error(t, err)
+
+	// redundant rows make JJᵀ singular, the dense step still finds the
+	// minimum norm
+	got, err = sparseNewtonStep(NewSparseMatrixFromDense(Matrix{{1, 1, 1}, {1, 1, 1}}), Vector{3, 3})
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, Vector{1, 1, 1}, got, 1e-12)
+}
+
+func TestSparse_SolveSystemRedundant(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("x", 0)
+	p.Add("y", 0)
+	p.Add("z", 0)
+
+	plane := Param("x").Add(Param("y")).Add(Param("z")).Subtract(Number(3))
+	sys := []*Expr{plane, plane}
+
+	report := SolveSystem(sys, p, SolverOptions{LinearSolver: SPARSE})
+
+	assert.Equal(t, CONVERGED, report.Result)
+	assert.InDelta(t, 1.0, p.Get("x"), 1e-9)
+	assert.InDelta(t, 1.0, p.Get("y"), 1e-9)
+	assert.InDelta(t, 1.0, p.Get("z"), 1e-9)
+}
+
+func BenchmarkSparse_Chain(b *testing.B) {
+	for _, n := range []int{25, 100} {
+		for _, solver := range []LinearSolver{DENSE, SPARSE} {
+			b.Run(fmt.Sprintf("params=%d/%s", 2*n, solver), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					sys, p := chain(n)
+					SolveSystem(sys, p, SolverOptions{LinearSolver: solver})
+				}
+			})
+		}
+	}
+
+	b.Run("params=2000/SPARSE", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sys, p := chain(1000)
+			SolveSystem(sys, p, SolverOptions{LinearSolver: SPARSE})
+		}
+	})
+}
@@ -0,0 +1,239 @@
+package solver
+
+import (
+	"math"
+)
+
+type opcode uint8
+
+const (
+	opConstant opcode = iota
+	opParameter
+	opAdd
+	opSubtract
+	opMultiply
+	opSquare
+	opNegate
+	opDivide
+	opSqrt
+	opSin
+	opCos
+	opAtan2
+	opAbs
+	opPow
+	opExp
+	opLog
+	opFunction
+)
+
+var opcodes = map[ExprType]opcode{
+	CONSTANT:  opConstant,
+	PARAMETER: opParameter,
+	ADD:       opAdd,
+	SUBTRACT:  opSubtract,
+	MULTIPLY:  opMultiply,
+	SQUARE:    opSquare,
+	NEGATE:    opNegate,
+	DIVIDE:    opDivide,
+	SQRT:      opSqrt,
+	SIN:       opSin,
+	COS:       opCos,
+	ATAN2:     opAtan2,
+	ABS:       opAbs,
+	POW:       opPow,
+	EXP:       opExp,
+	LOG:       opLog,
+	FUNCTION:  opFunction,
+}
+
+// instruction computes slot i of the tape from earlier slots. A parameter
+// reads params.list[left], or is 0 if left is -1. A function is calls[left].
+type instruction struct {
+	op    opcode
+	left  int32
+	right int32
+	value float64
+}
+
+// tape is a dag compiled to a flat list of instructions, one for each node,
+// that run in order without recursion, name lookups or allocations
+type tape struct {
+	instructions []instruction
+	slots        []float64
+	calls        []call
+
+	params *SystemParameters
+	at     []float64 // the parameter values slots were computed for
+	valid  bool
+	runs   int
+}
+
+// call is a black box function with the indices of its parameters in
+// params.list, -1 for unknown ones, and room for their values and for the
+// shifted values of central differences, so calling it doesn't allocate
+type call struct {
+	fn      *Function
+	args    []int32
+	values  []float64
+	scratch []float64
+}
+
+// compileTape compiles the nodes of a dag against the parameter list they
+// will be evaluated with
+func compileTape(nodes []dagNode, params *SystemParameters) *tape {
+	t := &tape{
+		instructions: make([]instruction, len(nodes)),
+		slots:        make([]float64, len(nodes)),
+		params:       params,
+		at:           make([]float64, len(params.list)),
+	}
+
+	index := map[string]int32{}
+	for j, p := range params.list {
+		index[p.name] = int32(j)
+	}
+
+	for i, n := range nodes {
+		op, ok := opcodes[n.Type]
+		if !ok {
+			panic("Can't compile")
+		}
+
+		in := instruction{op: op, left: int32(n.left), right: int32(n.right), value: n.value}
+
+		switch n.Type {
+		case PARAMETER:
+			in.left = lookup(index, n.name)
+		case FUNCTION:
+			in.left = int32(len(t.calls))
+			t.calls = append(t.calls, newCall(n.fn, index))
+		}
+
+		t.instructions[i] = in
+	}
+
+	return t
+}
+
+// lookup returns the index of the parameter or -1 if there's none
+func lookup(index map[string]int32, name string) int32 {
+	if j, ok := index[name]; ok {
+		return j
+	}
+
+	return -1
+}
+
+func newCall(fn *Function, index map[string]int32) call {
+	n := len(fn.Params)
+	c := call{fn, make([]int32, n), make([]float64, n), make([]float64, n)}
+	for k, name := range fn.Params {
+		c.args[k] = lookup(index, name)
+	}
+
+	return c
+}
+
+// eval calls the function with the current values of its parameters
+func (c *call) eval(list []*SParam) float64 {
+	for k, j := range c.args {
+		c.values[k] = 0
+		if j >= 0 {
+			c.values[k] = list[j].value
+		}
+	}
+
+	return c.apply()
+}
+
+// apply calls the function with values
+func (c *call) apply() float64 {
+	if c.fn.of != nil {
+		return c.fn.evalPartial(c.values, c.scratch)
+	}
+
+	return c.fn.Eval(c.values)
+}
+
+// derivative estimates the derivative by the k-th parameter at values
+func (c *call) derivative(k int) float64 {
+	return c.fn.derivative(c.values, k, c.scratch)
+}
+
+// compiledFor reports whether the tape can be run for these nodes and params
+func (t *tape) compiledFor(nodes []dagNode, params *SystemParameters) bool {
+	return t != nil && t.params == params && len(t.instructions) == len(nodes) && len(t.at) == len(params.list)
+}
+
+// run evaluates every slot at the current parameter values, unless they're
+// the ones of the last run
+func (t *tape) run() []float64 {
+	list := t.params.list
+
+	if t.valid {
+		unchanged := true
+		for j, p := range list {
+			if p.value != t.at[j] {
+				unchanged = false
+				break
+			}
+		}
+		if unchanged {
+			return t.slots
+		}
+	}
+
+	for j, p := range list {
+		t.at[j] = p.value
+	}
+
+	s := t.slots
+
+	for i, in := range t.instructions {
+		switch in.op {
+		case opConstant:
+			s[i] = in.value
+		case opParameter:
+			if in.left >= 0 {
+				s[i] = list[in.left].value
+			} else {
+				s[i] = 0
+			}
+		case opAdd:
+			s[i] = s[in.left] + s[in.right]
+		case opSubtract:
+			s[i] = s[in.left] - s[in.right]
+		case opMultiply:
+			s[i] = s[in.left] * s[in.right]
+		case opSquare:
+			s[i] = s[in.left] * s[in.left]
+		case opNegate:
+			s[i] = s[in.left] * -1.0
+		case opDivide:
+			s[i] = s[in.left] / s[in.right]
+		case opSqrt:
+			s[i] = math.Sqrt(s[in.left])
+		case opSin:
+			s[i] = math.Sin(s[in.left])
+		case opCos:
+			s[i] = math.Cos(s[in.left])
+		case opAtan2:
+			s[i] = math.Atan2(s[in.left], s[in.right])
+		case opAbs:
+			s[i] = math.Abs(s[in.left])
+		case opPow:
+			s[i] = math.Pow(s[in.left], s[in.right])
+		case opExp:
+			s[i] = math.Exp(s[in.left])
+		case opLog:
+			s[i] = math.Log(s[in.left])
+		case opFunction:
+			s[i] = t.calls[in.left].eval(list)
+		}
+	}
+
+	t.valid = true
+	t.runs++
+
+	return s
+}
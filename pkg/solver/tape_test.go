@@ -0,0 +1,101 @@
+package solver
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTape_EveryTypeHasAnOpcode(t *testing.T) {
+	for _, typ := range exprTypes(t) {
+		assert.Contains(t, opcodes, typ, "no opcode for %s", typ)
+	}
+}
+
+func TestTape_MatchesTree(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+
+	types := builderTypes()
+
+	p := &SystemParameters{}
+	p.Add("X", 0)
+	p.Add("Y", 0)
+
+	g := newDAG()
+	expressions := []*Expr{}
+	roots := []int{}
+	for i := 0; i < 300; i++ {
+		e := randomExpr(r, types, 4)
+		expressions = append(expressions, e)
+		roots = append(roots, g.intern(e))
+	}
+
+	for k := 0; k < 5; k++ {
+		p.saveVec([]float64{r.Float64()*4 - 2, r.Float64()*4 - 2})
+		values := g.eval(p)
+
+		for i, e := range expressions {
+			expected := e.Eval(p)
+			if math.IsNaN(expected) {
+				assert.True(t, math.IsNaN(values[roots[i]]))
+				continue
+			}
+			assert.Equal(t, expected, values[roots[i]], e.Format())
+		}
+	}
+}
+
+func TestTape_UnknownParameter(t *testing.T) {
+	p := &SystemParameters{}
+	p.Add("X", 2)
+
+	system := compileSystem([]*Expr{Param("X").Add(Param("Z"))}, p, SYMBOLIC)
+
+	assert.Equal(t, 2.0, system.residuals(p)[0])
+}
+
+func TestTape_RunDoesntAllocate(t *testing.T) {
+	equations, params := chain(20)
+	system := compileSystem(equations, params, SYMBOLIC)
+	system.residuals(params)
+	tape := system.graph.tape
+
+	allocations := testing.AllocsPerRun(100, func() {
+		params.list[0].value++
+		tape.run()
+	})
+
+	assert.Equal(t, 0.0, allocations)
+}
+
+func BenchmarkTape_Eval(b *testing.B) {
+	// the residuals and the Jacobian, at parameters that change every time
+	equations, params := chain(50)
+
+	b.Run("tree", func(b *testing.B) {
+		J := createJacobian(equations, params)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			params.list[0].value = float64(i)
+			evalSystem(equations, params)
+			evalJacobian(J, params)
+		}
+	})
+
+	b.Run("tape", func(b *testing.B) {
+		system := compileSystem(equations, params, SYMBOLIC)
+		system.residuals(params)
+		tape := system.graph.tape
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			params.list[0].value = float64(i)
+			tape.run()
+		}
+	})
+}